@@ -0,0 +1,152 @@
+// Package mongo provides aggregation pipeline support for models.
+package mongo
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Pipeline is an aggregation pipeline built stage by stage.
+// It can be passed to Model.Aggregate and Aggregate as is.
+//
+// Example:
+//
+//	pipeline := mongo.NewPipeline().
+//	    Match(mongo.Where("status").Eq("paid")).
+//	    Group("$user_id", mongo.Map().Set("total", mongo.Map().Set("$sum", "$amount"))).
+//	    Sort(mongo.Desc("total")).
+//	    Limit(10)
+type Pipeline []bson.D
+
+// NewPipeline creates an empty aggregation pipeline.
+func NewPipeline() Pipeline {
+	return Pipeline{}
+}
+
+// Stage appends a raw stage such as Stage("$addFields", fields).
+func (p Pipeline) Stage(name string, spec any) Pipeline {
+	return append(p, bson.D{{Key: name, Value: spec}})
+}
+
+// Match filters documents, see Query for building the filter.
+func (p Pipeline) Match(filter any) Pipeline {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	return p.Stage("$match", filter)
+}
+
+// Group groups documents by id and computes the accumulator fields.
+func (p Pipeline) Group(id any, fields M) Pipeline {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	group := bson.D{{Key: "_id", Value: id}}
+	for _, k := range keys {
+		group = append(group, bson.E{Key: k, Value: fields[k]})
+	}
+	return p.Stage("$group", group)
+}
+
+// Lookup joins documents of another collection where localField equals foreignField into the array field as.
+func (p Pipeline) Lookup(from, localField, foreignField, as string) Pipeline {
+	return p.Stage("$lookup", bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	})
+}
+
+// Unwind outputs one document per element of the array field at path.
+// With preserveNullAndEmpty, documents without elements are kept.
+func (p Pipeline) Unwind(path string, preserveNullAndEmpty ...bool) Pipeline {
+	if !strings.HasPrefix(path, "$") {
+		path = "$" + path
+	}
+	spec := bson.D{{Key: "path", Value: path}}
+	if len(preserveNullAndEmpty) > 0 && preserveNullAndEmpty[0] {
+		spec = append(spec, bson.E{Key: "preserveNullAndEmptyArrays", Value: true})
+	}
+	return p.Stage("$unwind", spec)
+}
+
+// Project reshapes documents, see Projection for simple inclusion and exclusion.
+func (p Pipeline) Project(projection any) Pipeline {
+	return p.Stage("$project", projection)
+}
+
+// Sort orders documents, see SortSpec.
+func (p Pipeline) Sort(sort any) Pipeline {
+	return p.Stage("$sort", sort)
+}
+
+// Skip skips the first n documents.
+func (p Pipeline) Skip(n int64) Pipeline {
+	return p.Stage("$skip", n)
+}
+
+// Limit passes at most n documents to the next stage.
+func (p Pipeline) Limit(n int64) Pipeline {
+	return p.Stage("$limit", n)
+}
+
+// Facet runs several sub-pipelines on the same input documents.
+func (p Pipeline) Facet(facets map[string]Pipeline) Pipeline {
+	names := make([]string, 0, len(facets))
+	for name := range facets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	spec := bson.D{}
+	for _, name := range names {
+		spec = append(spec, bson.E{Key: name, Value: facets[name]})
+	}
+	return p.Stage("$facet", spec)
+}
+
+// Aggregate runs an aggregation pipeline on the model collection and streams the results to cb.
+// The callback can return false to stop iteration early. The pipeline runs in the transaction context,
+// so it works inside multi-document transactions.
+func (m *Model) Aggregate(pipeline any, cb func(m M) (bool, error)) error {
+	return Aggregate(m, pipeline, func(doc *M) (bool, error) {
+		return cb(*doc)
+	})
+}
+
+// Aggregate runs an aggregation pipeline on the model collection and streams typed results to cb.
+//
+// Example:
+//
+//	err := mongo.Aggregate(txn.Model(&Order{}), pipeline, func(total *UserTotal) (bool, error) {
+//	    fmt.Println(total.UserID, total.Total)
+//	    return true, nil
+//	})
+func Aggregate[T any](m *Model, pipeline any, cb func(item *T) (bool, error)) error {
+	op := &Operation{Name: "Aggregate", Pipeline: pipeline}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		cursor, err := m.coll.Aggregate(m.txn.ctx, op.Pipeline)
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(m.txn.ctx)
+
+		for cursor.Next(m.txn.ctx) {
+			item := new(T)
+			if err := cursor.Decode(item); err != nil {
+				return nil, err
+			}
+			if ok, err := cb(item); err != nil || !ok {
+				return nil, err
+			}
+		}
+		return nil, cursor.Err()
+	})
+	return err
+}
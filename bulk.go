@@ -0,0 +1,239 @@
+// Package mongo provides batched bulk writes for models.
+package mongo
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultBulkBatchSize is the number of operations sent per BulkWrite call.
+const DefaultBulkBatchSize = 1000
+
+// BulkResult reports the outcome of a bulk write.
+type BulkResult struct {
+	Inserted int64
+	Matched  int64
+	Modified int64
+	Upserted int64
+	Deleted  int64
+
+	// Errors holds the failed operations; duplicate key failures are reported as ErrDuplicateKey.
+	Errors []*BulkError
+}
+
+// BulkError is the failure of a single operation of a bulk write.
+type BulkError struct {
+	// Index is the position of the operation in the bulk.
+	Index int
+
+	// Code is the server error code.
+	Code int
+
+	// Err is ErrDuplicateKey for duplicate key errors, otherwise the server error.
+	Err error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("bulk operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
+
+// Bulk groups Set, Update, Delete and Inc operations of a model into BulkWrite calls.
+//
+// Example:
+//
+//	res, err := txn.Model(&User{}).Bulk().
+//	    Set(user1, user2).
+//	    Inc("user3", mongo.Map().Set("login_count", 1)).
+//	    Delete("user4").
+//	    Ordered(false).
+//	    Do()
+type Bulk struct {
+	model     *Model
+	ops       []mongo.WriteModel
+	batchSize int
+	ordered   bool
+	err       error
+}
+
+// Bulk starts an ordered bulk write with the default batch size.
+func (m *Model) Bulk() *Bulk {
+	return &Bulk{model: m, batchSize: DefaultBulkBatchSize, ordered: true}
+}
+
+// BatchSize sets the number of operations sent per BulkWrite call.
+func (b *Bulk) BatchSize(n int) *Bulk {
+	if n > 0 {
+		b.batchSize = n
+	}
+	return b
+}
+
+// Ordered sets whether operations run in order and stop at the first error (the default),
+// or run in any order and continue after errors.
+func (b *Bulk) Ordered(ordered bool) *Bulk {
+	b.ordered = ordered
+	return b
+}
+
+// Set adds an upsert of each record by its ID, like Model.Set.
+// BeforeSave hooks run when the record is added; AfterSave hooks don't run. Version conflicts are reported as ErrVersionConflict item errors.
+// The created_at field of the records is not filled, as the stored creation time isn't read back.
+func (b *Bulk) Set(records ...any) *Bulk {
+	for _, record := range records {
+		if err := b.model.beforeSave(record); err != nil {
+			b.fail(err)
+			continue
+		}
+		w, err := b.model.prepareSet(record, nil)
+		if err != nil {
+			b.fail(err)
+			continue
+		}
+		if w.replacement != nil {
+			b.ops = append(b.ops, mongo.NewReplaceOneModel().
+				SetFilter(w.filter).
+				SetReplacement(w.replacement).
+				SetUpsert(true))
+			continue
+		}
+		b.ops = append(b.ops, mongo.NewUpdateOneModel().
+			SetFilter(w.filter).
+			SetUpdate(w.update).
+			SetUpsert(true))
+	}
+	return b
+}
+
+// Update adds a partial update of each record by its ID, like Model.Update.
+// BeforeUpdate hooks run when the record is added; AfterSave hooks don't run.
+// Records that don't exist are not created, nor are records whose version doesn't match;
+// compare BulkResult.Matched with the number of updates to detect them.
+func (b *Bulk) Update(records ...any) *Bulk {
+	for _, record := range records {
+		if err := b.model.beforeUpdate(record); err != nil {
+			b.fail(err)
+			continue
+		}
+		w, err := b.model.prepareUpdate(record, nil)
+		if err != nil {
+			b.fail(err)
+			continue
+		}
+		b.ops = append(b.ops, mongo.NewUpdateOneModel().
+			SetFilter(b.model.scoped(w.filter)).
+			SetUpdate(w.update))
+	}
+	return b
+}
+
+// Delete adds a removal of each ID, like Model.Del, without BeforeDelete hooks.
+// Soft deletes are updates, so they are counted in BulkResult.Modified instead of Deleted.
+func (b *Bulk) Delete(ids ...any) *Bulk {
+	for _, id := range ids {
+		if b.model.softDeletes() {
+			b.ops = append(b.ops, b.model.softDelete(GetIDFilter(id)))
+			continue
+		}
+		b.ops = append(b.ops, mongo.NewDeleteOneModel().SetFilter(GetIDFilter(id)))
+	}
+	return b
+}
+
+// Inc adds an atomic increment of numeric fields, like Model.Inc.
+func (b *Bulk) Inc(id, fields any) *Bulk {
+	b.ops = append(b.ops, mongo.NewUpdateOneModel().
+		SetFilter(b.model.scoped(GetIDFilter(id))).
+		SetUpdate(bson.D{{Key: "$inc", Value: fields}}))
+	return b
+}
+
+// Len returns the number of queued operations.
+func (b *Bulk) Len() int {
+	return len(b.ops)
+}
+
+func (b *Bulk) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Do sends the queued operations in batches. Nothing is written if an operation could not be
+// built, e.g. a record without ID. Failed operations are listed in BulkResult.Errors and the
+// first of them is returned as the error; ordered bulks stop at the first failure.
+func (b *Bulk) Do() (*BulkResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	op := &Operation{Name: "Bulk", Options: Map().Set("operations", len(b.ops)).Set("ordered", b.ordered)}
+	return run(b.model, op, func(m *Model, op *Operation) (*BulkResult, error) {
+		return b.do(m)
+	})
+}
+
+func (b *Bulk) do(m *Model) (*BulkResult, error) {
+	res := &BulkResult{}
+	opt := options.BulkWrite().SetOrdered(b.ordered)
+	for start := 0; start < len(b.ops); start += b.batchSize {
+		end := start + b.batchSize
+		if end > len(b.ops) {
+			end = len(b.ops)
+		}
+
+		r, err := m.coll.BulkWrite(m.txn.ctx, b.ops[start:end], opt)
+		if r != nil {
+			res.Inserted += r.InsertedCount
+			res.Matched += r.MatchedCount
+			res.Modified += r.ModifiedCount
+			res.Upserted += r.UpsertedCount
+			res.Deleted += r.DeletedCount
+		}
+		if err != nil {
+			var bwe mongo.BulkWriteException
+			if !errors.As(err, &bwe) || len(bwe.WriteErrors) == 0 {
+				return res, err
+			}
+			for _, we := range bwe.WriteErrors {
+				itemErr := &BulkError{Index: start + we.Index, Code: we.Code, Err: we.WriteError}
+				if b.model.schema.version != nil && isIDConflictError(we.WriteError) {
+					itemErr.Err = ErrVersionConflict
+				} else if we.Code == 11000 || isDuplicateKeyError(we.WriteError) {
+					itemErr.Err = ErrDuplicateKey
+				}
+				res.Errors = append(res.Errors, itemErr)
+			}
+			if b.ordered {
+				break
+			}
+		}
+	}
+
+	if len(res.Errors) > 0 {
+		return res, res.Errors[0]
+	}
+	return res, nil
+}
+
+// BulkSet upserts records by their IDs in batches, see Bulk.Set.
+func (m *Model) BulkSet(records []any) (*BulkResult, error) {
+	return m.Bulk().Set(records...).Do()
+}
+
+// BulkUpdate partially updates records by their IDs in batches, see Bulk.Update.
+func (m *Model) BulkUpdate(records []any) (*BulkResult, error) {
+	return m.Bulk().Update(records...).Do()
+}
+
+// BulkDelete removes documents by their IDs in batches, see Bulk.Delete.
+func (m *Model) BulkDelete(ids []any) (*BulkResult, error) {
+	return m.Bulk().Delete(ids...).Do()
+}
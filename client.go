@@ -29,8 +29,10 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // Client wraps the official MongoDB client with enhanced functionality.
@@ -50,6 +52,7 @@ func (c *Client) Close() error {
 
 // NewClient creates a new MongoDB client with the given connection URI.
 // Optional client options can be provided to customize the connection behavior.
+// It is a thin wrapper over Connect that exits the process if the client cannot be created.
 //
 // Example:
 //
@@ -61,16 +64,127 @@ func NewClient(connectionURI string, opts ...func(c *ClientOptions)) *Client {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	opt := options.Client().ApplyURI(connectionURI)
+	client, err := Connect(ctx, connectionURI, withClientOptions(opts))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return client
+}
+
+// Connect creates a new MongoDB client with the given connection URI and returns any error
+// instead of exiting. If ConnectOptions.Ping is set, the primary is pinged according to the
+// retry policy before returning, and the client is disconnected when every attempt fails.
+//
+// Example:
+//
+//	client, err := mongo.Connect(ctx, uri, func(c *mongo.ConnectOptions) {
+//	    c.SetMaxPoolSize(100)
+//	    c.Ping = &mongo.RetryPolicy{Attempts: 5, Backoff: time.Second}
+//	})
+func Connect(ctx context.Context, connectionURI string, opts ...func(c *ConnectOptions)) (*Client, error) {
+	return connect(ctx, newConnectOptions(connectionURI, opts))
+}
+
+// newConnectOptions applies the option functions to the options of the given URI.
+func newConnectOptions(connectionURI string, opts []func(c *ConnectOptions)) *ConnectOptions {
+	opt := &ConnectOptions{ClientOptions: options.Client().ApplyURI(connectionURI)}
 	for _, v := range opts {
 		v(opt)
 	}
+	return opt
+}
+
+// connect creates the client described by opt.
+func connect(ctx context.Context, opt *ConnectOptions) (*Client, error) {
+	if opt.Logger != nil {
+		monitor := CommandMonitor(opt.Logger, opt.logOptions)
+		if opt.Monitor != nil {
+			monitor = chainMonitors(opt.Monitor, monitor)
+		}
+		opt.SetMonitor(monitor)
+	}
+	if opt.Metrics != nil {
+		monitor := PoolMonitor(opt.Metrics)
+		if opt.PoolMonitor != nil {
+			previous := opt.PoolMonitor.Event
+			record := monitor.Event
+			monitor.Event = func(e *event.PoolEvent) {
+				if previous != nil {
+					previous(e)
+				}
+				record(e)
+			}
+		}
+		opt.SetPoolMonitor(monitor)
+	}
 
-	client, err := mongo.Connect(ctx, opt)
+	client, err := mongo.Connect(ctx, opt.ClientOptions)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
+	}
+
+	if opt.Ping != nil {
+		err = opt.Ping.do(ctx, func(ctx context.Context) error {
+			return client.Ping(ctx, readpref.Primary())
+		})
+		if err != nil {
+			client.Disconnect(context.Background())
+			return nil, errors.Wrap(err, "ping")
+		}
+	}
+	return &Client{Client: client}, nil
+}
+
+// RetryPolicy describes how many times an operation is attempted and how long to wait between attempts.
+// The delay starts at Backoff and is multiplied by Multiplier after every failed attempt, up to MaxBackoff.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts. Values below 1 mean a single attempt.
+	Attempts int
+
+	// Backoff is the delay before the second attempt.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after each failed attempt. Values below 1 default to 2.
+	Multiplier float64
+}
+
+// do runs fn until it succeeds, the attempts are exhausted or ctx is done.
+func (p *RetryPolicy) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := p.Backoff
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Wrap(ctx.Err(), err.Error())
+			case <-timer.C:
+			}
+
+			delay = time.Duration(float64(delay) * multiplier)
+			if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+				delay = p.MaxBackoff
+			}
+		}
+
+		if err = fn(ctx); err == nil {
+			return nil
+		}
 	}
-	return &Client{Client: client}
+	return err
 }
 
 // ParseTLSConfig creates a TLS configuration from PEM certificate data.
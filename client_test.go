@@ -0,0 +1,31 @@
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectPingRetry(t *testing.T) {
+	ctx := context.Background()
+
+	start := time.Now()
+	_, err := mongo.Connect(ctx, "mongodb://127.0.0.1:1/?directConnection=true", func(c *mongo.ConnectOptions) {
+		c.SetServerSelectionTimeout(50 * time.Millisecond)
+		c.Ping = &mongo.RetryPolicy{Attempts: 3, Backoff: 20 * time.Millisecond}
+	})
+	require.Error(t, err)
+	// three pings plus two backoffs (20ms + 40ms)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	_, err = mongo.OpenDatabase(ctx, "mongodb://127.0.0.1:1/?directConnection=true", "test", func(c *mongo.ConnectOptions) {
+		c.SetServerSelectionTimeout(10 * time.Millisecond)
+		c.Ping = &mongo.RetryPolicy{Attempts: 10, Backoff: time.Second}
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
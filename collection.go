@@ -0,0 +1,155 @@
+// Package mongo provides a generic, typed repository API on top of Model.
+package mongo
+
+import "iter"
+
+// Collection is a typed view of a model collection.
+// It wraps Model and converts documents to *T, returning decode errors instead of panicking.
+// AfterFind hooks run on the decoded *T, so fields they compute are kept.
+//
+// Example:
+//
+//	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+//	    users := mongo.NewCollection[User](txn)
+//	    user, err := users.Get("user123")
+//	    if err != nil {
+//	        return err
+//	    }
+//	    user.Age++
+//	    return users.Set(user)
+//	})
+type Collection[T any] struct {
+	model *Model
+}
+
+// NewCollection creates a typed collection for T within the given transaction.
+// The collection name is derived from T in the same way as NewModel.
+func NewCollection[T any](txn *Txn) *Collection[T] {
+	model := NewModel(txn, new(T))
+	model.skipAfterFind = true
+	return &Collection[T]{model: model}
+}
+
+// Model returns the underlying untyped Model.
+func (c *Collection[T]) Model() *Model {
+	m := *c.model
+	m.skipAfterFind = false
+	return &m
+}
+
+// decode converts a document to *T and runs its AfterFind hook.
+func (c *Collection[T]) decode(doc M) (*T, error) {
+	item, err := Decode[T](doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := runAfterFind(c.model.txn.ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// decodeAll converts documents to []*T and runs their AfterFind hooks.
+func (c *Collection[T]) decodeAll(list []M) ([]*T, error) {
+	items := make([]*T, 0, len(list))
+	for _, doc := range list {
+		item, err := c.decode(doc)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Get retrieves a record by ID with optional field projection.
+// Returns ErrRecordNotFound if the record doesn't exist.
+func (c *Collection[T]) Get(id any, projection ...any) (*T, error) {
+	doc, err := c.model.Get(id, projection...)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(doc)
+}
+
+// First retrieves the first record matching the filter.
+// Supports sorting and field projection.
+func (c *Collection[T]) First(filter, sort any, projection ...any) (*T, error) {
+	doc, err := c.model.First(filter, sort, projection...)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(doc)
+}
+
+// Find retrieves all records matching the filter.
+// Supports sorting and field projection.
+func (c *Collection[T]) Find(filter, sort any, projection ...any) ([]*T, error) {
+	list, err := c.model.Find(filter, sort, projection...)
+	if err != nil {
+		return nil, err
+	}
+	return c.decodeAll(list)
+}
+
+// Pagination retrieves paginated records with total count.
+// Supports filtering, sorting, and field projection.
+func (c *Collection[T]) Pagination(filter, sort any, page, pageSize int64, projection ...any) (int64, []*T, error) {
+	total, list, err := c.model.Pagination(filter, sort, page, pageSize, projection...)
+	if err != nil {
+		return 0, nil, err
+	}
+	items, err := c.decodeAll(list)
+	if err != nil {
+		return 0, nil, err
+	}
+	return total, items, nil
+}
+
+// List iterates over records matching the filter in ascending order.
+// The callback can return false to stop iteration early.
+func (c *Collection[T]) List(filter M, cb func(item *T) (bool, error), projection ...any) error {
+	return c.model.List(filter, func(m M) (bool, error) {
+		item, err := c.decode(m)
+		if err != nil {
+			return false, err
+		}
+		return cb(item)
+	}, projection...)
+}
+
+// All returns an iterator over the records matching the filter, see Model.All.
+func (c *Collection[T]) All(filter M, opts ...func(o *IterOptions)) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for doc, err := range c.model.All(filter, opts...) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			item, err := c.decode(doc)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Update updates a record and returns the updated record.
+// The parameter 'update' can be a *T or a Map containing the primary key.
+func (c *Collection[T]) Update(update any, opts ...func(o *UpdateOptions)) (*T, error) {
+	return UpdateAndGet[T](c.model, update, opts...)
+}
+
+// Set creates or updates a record (upsert operation).
+func (c *Collection[T]) Set(record *T) error {
+	return c.model.Set(record)
+}
+
+// Delete removes a record by its ID.
+func (c *Collection[T]) Delete(id any) error {
+	return c.model.Del(id)
+}
@@ -0,0 +1,307 @@
+// Package mongo provides keyset pagination with opaque cursors.
+package mongo
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetCursorSecret sets the key signing the cursors returned by PageByCursor with HMAC-SHA256, so clients
+// cannot forge or alter them. By default every Database signs with its own random key, so cursors are
+// rejected by other processes and after a restart; share a secret to accept them there.
+// A nil secret disables signing, leaving cursors only encoded.
+// SetCursorSecret is not safe to call concurrently with operations.
+func (d *Database) SetCursorSecret(secret []byte) {
+	d.cursorSecret = secret
+}
+
+// newCursorSecret returns a random key for signing cursors.
+func newCursorSecret() []byte {
+	secret := make([]byte, sha256.Size)
+	rand.Read(secret) // never fails, see crypto/rand.Read
+	return secret
+}
+
+// cursorToken is the content of a PageByCursor cursor.
+type cursorToken struct {
+	// Sort is the normalized sort the cursor was created for.
+	Sort bson.D `bson:"s"`
+	// Values are the sort values of the boundary document.
+	Values bson.A `bson:"v"`
+	// Before selects the documents before the boundary instead of after it.
+	Before bool `bson:"b,omitempty"`
+}
+
+// cursorPage is the result of a PageByCursor operation.
+type cursorPage struct {
+	list       []M
+	next, prev string
+}
+
+// PageByCursor retrieves a page using keyset pagination, which stays fast on deep pages unlike Pagination.
+// Documents are ordered by sort, which must be ordered (SortSpec or bson.D) and is completed with
+// _id as tiebreaker. An empty cursor returns the first page; pass next or prev from a previous call
+// with the same filter and sort to move forward or backward. Empty cursors are returned at either end.
+// Cursors are signed, by default with a random key of the Database, see SetCursorSecret.
+// A projection must keep the sort fields.
+//
+// Example:
+//
+//	list, next, prev, err := txn.Model(&User{}).PageByCursor(filter, mongo.Desc("created_at"), "", 20)
+//	list, next, prev, err = txn.Model(&User{}).PageByCursor(filter, mongo.Desc("created_at"), next, 20)
+func (m *Model) PageByCursor(filter, sort any, cursor string, limit int64, projection ...any) (list []M, next, prev string, err error) {
+	if limit < 1 {
+		limit = 10
+	}
+
+	op := &Operation{
+		Name:       "PageByCursor",
+		Filter:     filter,
+		Sort:       sort,
+		Projection: first(projection),
+		Options:    Map().Set("cursor", cursor).Set("limit", limit),
+	}
+	res, err := run(m, op, func(m *Model, op *Operation) (p cursorPage, err error) {
+		spec, err := keysetSort(op.Sort)
+		if err != nil {
+			return
+		}
+
+		query, err := toDocument(op.Filter)
+		if err != nil {
+			return
+		}
+
+		token := &cursorToken{Sort: spec}
+		if cursor != "" {
+			if token, err = m.decodeCursor(cursor, spec); err != nil {
+				return
+			}
+			keyset := keysetFilter(spec, token.Values, token.Before)
+			if len(query) > 0 {
+				query = bson.D{{Key: "$and", Value: bson.A{query, keyset}}}
+			} else {
+				query = keyset
+			}
+		}
+
+		findSort := spec
+		if token.Before {
+			findSort = reverseSort(spec)
+		}
+		opt := options.Find().SetSort(findSort).SetLimit(limit + 1)
+		if op.Projection != nil {
+			opt.SetProjection(op.Projection)
+		}
+		if p.list, err = m.find(query, opt); err != nil {
+			return
+		}
+
+		more := int64(len(p.list)) > limit
+		if more {
+			p.list = p.list[:limit]
+		}
+		if token.Before {
+			for i, j := 0, len(p.list)-1; i < j; i, j = i+1, j-1 {
+				p.list[i], p.list[j] = p.list[j], p.list[i]
+			}
+		}
+		if len(p.list) == 0 {
+			return
+		}
+
+		// moving backward always leaves a next page, moving forward a previous one unless on the first page
+		hasNext, hasPrev := more, cursor != ""
+		if token.Before {
+			hasNext, hasPrev = true, more
+		}
+		if hasNext {
+			if p.next, err = m.encodeCursor(spec, p.list[len(p.list)-1], false); err != nil {
+				return
+			}
+		}
+		if hasPrev {
+			p.prev, err = m.encodeCursor(spec, p.list[0], true)
+		}
+		return
+	})
+	return res.list, res.next, res.prev, err
+}
+
+// keysetSort normalizes a sort to directions of 1 or -1 and appends _id as tiebreaker.
+func keysetSort(sort any) (bson.D, error) {
+	spec := bson.D{}
+	if !isEmptyFilter(sort) {
+		if val := reflect.ValueOf(sort); val.Kind() == reflect.Map && val.Len() > 1 {
+			return nil, errors.New("sort of several fields must be ordered, use SortSpec or bson.D")
+		}
+		doc, err := toDocument(sort)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range doc {
+			if _, ok := e.Value.(bson.D); ok {
+				return nil, errors.Errorf("unsupported sort on %s", e.Key)
+			}
+			dir := int32(1)
+			if toFloat(e.Value) < 0 {
+				dir = -1
+			}
+			spec = append(spec, bson.E{Key: e.Key, Value: dir})
+		}
+	}
+	if _, ok := docGet(spec, "_id"); !ok {
+		spec = append(spec, bson.E{Key: "_id", Value: int32(1)})
+	}
+	return spec, nil
+}
+
+// reverseSort returns the sort with every direction inverted.
+func reverseSort(spec bson.D) bson.D {
+	out := make(bson.D, len(spec))
+	for i, e := range spec {
+		out[i] = bson.E{Key: e.Key, Value: -e.Value.(int32)}
+	}
+	return out
+}
+
+// keysetFilter matches the documents after (or before) the given sort values:
+// {$or: [{a: {$gt: va}}, {a: va, b: {$gt: vb}}, ...]}.
+// Null and missing values sort first, so they bound the range explicitly, see keysetRange.
+func keysetFilter(spec bson.D, values bson.A, before bool) bson.D {
+	or := make(bson.A, 0, len(spec))
+	for i, e := range spec {
+		higher := (e.Value.(int32) < 0) == before
+		rng, ok := keysetRange(e.Key, values[i], higher)
+		if !ok {
+			continue
+		}
+		cond := make(bson.D, 0, i+1)
+		for j := 0; j < i; j++ {
+			cond = append(cond, bson.E{Key: spec[j].Key, Value: values[j]})
+		}
+		or = append(or, append(cond, rng))
+	}
+	if len(or) == 0 {
+		// nothing lies beyond the boundary
+		return bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{}}}}}
+	}
+	return bson.D{{Key: "$or", Value: or}}
+}
+
+// keysetRange returns the condition on key matching the values higher (or lower) than v,
+// or false if there are none. Null and missing values are below all others, which $gt and $lt
+// don't match: above null lies every other value, below it nothing, and below any other value
+// lie the nulls too.
+func keysetRange(key string, v any, higher bool) (bson.E, bool) {
+	switch {
+	case v == nil && higher:
+		return bson.E{Key: key, Value: bson.D{{Key: "$ne", Value: nil}}}, true
+	case v == nil:
+		return bson.E{}, false
+	case higher:
+		return bson.E{Key: key, Value: bson.D{{Key: "$gt", Value: v}}}, true
+	}
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: key, Value: bson.D{{Key: "$lt", Value: v}}}},
+		bson.D{{Key: key, Value: nil}},
+	}}, true
+}
+
+// encodeCursor returns the cursor of the documents after (or before) doc.
+func (m *Model) encodeCursor(spec bson.D, doc M, before bool) (string, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return "", err
+	}
+
+	token := cursorToken{Sort: spec, Values: make(bson.A, len(spec)), Before: before}
+	for i, e := range spec {
+		token.Values[i], _ = getPath(d, e.Key)
+	}
+
+	data, err := bson.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	if secret := m.txn.db.cursorSecret; len(secret) > 0 {
+		data = append(data, cursorMAC(secret, data)...)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor verifies and decodes a cursor created for spec.
+func (m *Model) decodeCursor(cursor string, spec bson.D) (*cursorToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if secret := m.txn.db.cursorSecret; len(secret) > 0 {
+		if len(data) < sha256.Size {
+			return nil, ErrInvalidCursor
+		}
+		mac := data[len(data)-sha256.Size:]
+		data = data[:len(data)-sha256.Size]
+		if !hmac.Equal(mac, cursorMAC(secret, data)) {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	token := &cursorToken{}
+	if err := bson.Unmarshal(data, token); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if len(token.Values) != len(spec) || len(token.Sort) != len(spec) {
+		return nil, ErrInvalidCursor
+	}
+	for i, e := range token.Sort {
+		if e.Key != spec[i].Key || !valuesEqual(e.Value, spec[i].Value) {
+			return nil, ErrInvalidCursor
+		}
+	}
+	// the values become query conditions, so they must not carry operators
+	for _, v := range token.Values {
+		if hasOperatorKeys(v) {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return token, nil
+}
+
+// hasOperatorKeys reports whether v holds a document with a "$" prefixed key.
+func hasOperatorKeys(v any) bool {
+	switch t := v.(type) {
+	case bson.D:
+		for _, e := range t {
+			if isOperator(e.Key) || hasOperatorKeys(e.Value) {
+				return true
+			}
+		}
+	case bson.M:
+		for k, e := range t {
+			if isOperator(k) || hasOperatorKeys(e) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, e := range t {
+			if hasOperatorKeys(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func cursorMAC(secret, data []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,209 @@
+package mongo_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMemoryPageByCursor(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	db.SetCursorSecret([]byte("secret"))
+
+	ages := []int64{30, 20, 30, 40, 20, 30, 10}
+	for i, age := range ages {
+		require.NoError(t, db.Set(&memUser{ID: string(rune('a' + i)), Name: string(rune('a' + i)), Age: age}))
+	}
+	// age descending, _id ascending on ties
+	want := []string{"d", "a", "c", "f", "b", "e", "g"}
+
+	ids := func(list []mongo.M) (out []string) {
+		for _, m := range list {
+			out = append(out, m["_id"].(string))
+		}
+		return
+	}
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&memUser{})
+		sort := mongo.Desc("age")
+
+		var got []string
+		var pages []string
+		cursor := ""
+		for {
+			list, next, prev, err := model.PageByCursor(nil, sort, cursor, 3)
+			require.NoError(t, err)
+			require.Equal(t, cursor == "", prev == "")
+			got = append(got, ids(list)...)
+			pages = append(pages, next)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		require.Equal(t, want, got)
+		require.Len(t, pages, 3)
+
+		// walk back from the last page
+		list, next, prev, err := model.PageByCursor(nil, sort, pages[1], 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"g"}, ids(list))
+		require.Empty(t, next)
+
+		list, next, prev, err = model.PageByCursor(nil, sort, prev, 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"f", "b", "e"}, ids(list))
+		require.NotEmpty(t, next)
+
+		list, _, prev, err = model.PageByCursor(nil, sort, prev, 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"d", "a", "c"}, ids(list))
+		require.Empty(t, prev)
+
+		// filters are combined with the keyset and left untouched
+		filter := mongo.Map().Set("age", mongo.Map().Set("$gte", 20))
+		list, next, _, err = model.PageByCursor(filter, sort, "", 4)
+		require.NoError(t, err)
+		require.Equal(t, []string{"d", "a", "c", "f"}, ids(list))
+		list, next, _, err = model.PageByCursor(filter, sort, next, 4)
+		require.NoError(t, err)
+		require.Equal(t, []string{"b", "e"}, ids(list))
+		require.Empty(t, next)
+		require.Equal(t, mongo.Map().Set("age", mongo.Map().Set("$gte", 20)), filter)
+
+		// tampered cursors and cursors of another sort are rejected
+		_, _, _, err = model.PageByCursor(nil, sort, pages[0][:len(pages[0])-2]+"AA", 3)
+		require.ErrorIs(t, err, mongo.ErrInvalidCursor)
+		_, _, _, err = model.PageByCursor(nil, mongo.Asc("age"), pages[0], 3)
+		require.ErrorIs(t, err, mongo.ErrInvalidCursor)
+		_, _, _, err = model.PageByCursor(nil, mongo.M{"age": 1, "name": 1}, "", 3)
+		require.Error(t, err)
+
+		// Next leaves the caller's filter untouched
+		filter = mongo.Map()
+		_, err = model.Next(filter, nil, "a", 2)
+		require.NoError(t, err)
+		require.Empty(t, filter)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMemoryPageByCursorNulls(t *testing.T) {
+	type Item struct {
+		ID string `bson:"_id"`
+		A  *int64 `bson:"a,omitempty"`
+	}
+
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	require.NoError(t, db.Set(&Item{ID: "w", A: mongo.Pointer(int64(2))}))
+	require.NoError(t, db.Set(&Item{ID: "x"}))
+	require.NoError(t, db.Set(&Item{ID: "y", A: mongo.Pointer(int64(1))}))
+	require.NoError(t, db.Set(&Item{ID: "z"}))
+
+	ids := func(list []mongo.M) (out []string) {
+		for _, m := range list {
+			out = append(out, m["_id"].(string))
+		}
+		return
+	}
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&Item{})
+
+		// missing values sort first ascending and last descending
+		for sort, want := range map[string][]string{
+			"asc":  {"x", "z", "y", "w"},
+			"desc": {"w", "y", "x", "z"},
+		} {
+			spec := mongo.Asc("a")
+			if sort == "desc" {
+				spec = mongo.Desc("a")
+			}
+			for _, limit := range []int64{1, 2, 3} {
+				var got []string
+				var prevs []string
+				cursor := ""
+				for {
+					list, next, prev, err := model.PageByCursor(nil, spec, cursor, limit)
+					require.NoError(t, err)
+					got = append(got, ids(list)...)
+					prevs = append(prevs, prev)
+					if next == "" {
+						break
+					}
+					cursor = next
+				}
+				require.Equal(t, want, got, "%s by %d", sort, limit)
+
+				// walking back from the last page returns the previous pages
+				if prev := prevs[len(prevs)-1]; prev != "" {
+					list, _, _, err := model.PageByCursor(nil, spec, prev, limit)
+					require.NoError(t, err)
+					end := len(want) - len(want)%int(limit)
+					if end == len(want) {
+						end -= int(limit)
+					}
+					require.Equal(t, want[end-int(limit):end], ids(list), "%s by %d", sort, limit)
+				}
+			}
+		}
+
+		// cursors are signed by default, so clients can't forge them
+		data, err := bson.Marshal(bson.D{
+			{Key: "s", Value: bson.D{{Key: "a", Value: int32(1)}, {Key: "_id", Value: int32(1)}}},
+			{Key: "v", Value: bson.A{bson.D{{Key: "$ne", Value: 0}}, "x"}},
+		})
+		require.NoError(t, err)
+		forged := base64.RawURLEncoding.EncodeToString(data)
+		_, _, _, err = model.PageByCursor(nil, mongo.Asc("a"), forged, 2)
+		require.ErrorIs(t, err, mongo.ErrInvalidCursor)
+
+		// nor inject operators into the keyset conditions when signing is disabled
+		db.SetCursorSecret(nil)
+		_, _, _, err = model.PageByCursor(nil, mongo.Asc("a"), forged, 2)
+		require.ErrorIs(t, err, mongo.ErrInvalidCursor)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMemoryPageByCursorDefaultKey(t *testing.T) {
+	ctx := context.Background()
+	page := func(db *mongo.Database, cursor string) (next string, err error) {
+		err = db.Txn(ctx, func(txn *mongo.Txn) error {
+			_, next, _, err = txn.Model(&memUser{}).PageByCursor(nil, mongo.Asc("age"), cursor, 1)
+			return err
+		})
+		return
+	}
+
+	db, other := mongo.NewMemoryDatabase("test"), mongo.NewMemoryDatabase("test")
+	for _, d := range []*mongo.Database{db, other} {
+		require.NoError(t, d.Set(&memUser{ID: "a", Name: "a", Age: 1}))
+		require.NoError(t, d.Set(&memUser{ID: "b", Name: "b", Age: 2}))
+	}
+
+	// each database signs with its own random key unless they share a secret
+	next, err := page(db, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+	_, err = page(db, next)
+	require.NoError(t, err)
+	_, err = page(other, next)
+	require.ErrorIs(t, err, mongo.ErrInvalidCursor)
+
+	db.SetCursorSecret([]byte("shared"))
+	other.SetCursorSecret([]byte("shared"))
+	next, err = page(db, "")
+	require.NoError(t, err)
+	_, err = page(other, next)
+	require.NoError(t, err)
+}
@@ -3,14 +3,10 @@ package mongo
 
 import (
 	"context"
-	"fmt"
-	"strings"
+	"log"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // Database represents a MongoDB database connection with enhanced operations.
@@ -18,10 +14,42 @@ import (
 type Database struct {
 	*Client
 	*mongo.Database
+
+	// storage serves collections instead of the driver when set, see NewMemoryDatabase.
+	storage Backend
+
+	// middlewares wrap every Model operation, see Use.
+	middlewares []Middleware
+
+	// recorder receives transaction metrics, see Instrument.
+	recorder Recorder
+
+	// cursorSecret signs PageByCursor cursors, random unless set with SetCursorSecret.
+	cursorSecret []byte
+
+	// Timeout bounds the convenience methods (Set, Update, First, ...) when the caller's
+	// context has no deadline. Zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+// DefaultTimeout is the timeout applied by the convenience methods when Database.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+// withTimeout derives a context bounded by the database timeout unless ctx already has a deadline.
+func (d *Database) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // NewDatabase creates a new database connection with the specified URL and database name.
 // Optional client options can be provided to customize the connection behavior.
+// It is a thin wrapper over OpenDatabase that exits the process if the client cannot be created.
 //
 // Example:
 //
@@ -30,8 +58,39 @@ type Database struct {
 //	    c.SetMaxPoolSize(100)
 //	})
 func NewDatabase(url string, name string, opts ...func(c *ClientOptions)) *Database {
-	client := NewClient(url, opts...)
-	return &Database{Client: client, Database: client.Database(name)}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db, err := OpenDatabase(ctx, url, name, withClientOptions(opts))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return db
+}
+
+// OpenDatabase creates a new database connection and returns any error instead of exiting.
+// See Connect for the available options, including the ping retry policy.
+//
+// Example:
+//
+//	db, err := mongo.OpenDatabase(ctx, "mongodb://localhost:27017", "myapp", func(c *mongo.ConnectOptions) {
+//	    c.Ping = &mongo.RetryPolicy{Attempts: 3, Backoff: time.Second}
+//	})
+func OpenDatabase(ctx context.Context, url string, name string, opts ...func(c *ConnectOptions)) (*Database, error) {
+	opt := newConnectOptions(url, opts)
+	client, err := connect(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &Database{Client: client, Database: client.Database(name), cursorSecret: newCursorSecret()}
+	if opt.Logger != nil {
+		db.Use(Logging(opt.Logger, opt.logOptions))
+	}
+	if opt.Metrics != nil {
+		db.Instrument(opt.Metrics)
+	}
+	return db, nil
 }
 
 // Close closes the database connection and cleans up resources.
@@ -46,17 +105,12 @@ func (d *Database) Close() {
 // Txn executes a transaction with the given function. By default, MongoDB will automatically abort any multi-document transaction that runs for more than 60 seconds.
 func (d *Database) Txn(ctx context.Context, fn func(txn *Txn) error, multiDoc ...bool) error {
 	if len(multiDoc) > 0 && multiDoc[0] {
-		// read preference in a transaction must be primary
-		sesstionOptions := &options.SessionOptions{DefaultReadPreference: readpref.Primary()}
-		session, err := d.Client.StartSession(sesstionOptions)
-		if err != nil {
-			return err
-		}
-		defer session.EndSession(ctx)
-
-		_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
-			return nil, fn(&Txn{ctx: sc, db: d})
+		attempts := 0
+		err := d.backend().WithTransaction(ctx, func(ctx context.Context) error {
+			attempts++
+			return fn(&Txn{ctx: ctx, db: d})
 		})
+		d.recordTxn(attempts, err)
 		return err
 	}
 
@@ -65,75 +119,21 @@ func (d *Database) Txn(ctx context.Context, fn func(txn *Txn) error, multiDoc ..
 
 // Indexes creates indexes for the given models based on their struct tags.
 // It supports both single and compound indexes with automatic naming.
-// Duplicate indexes are automatically skipped.
+// Existing indexes are matched by their ordered keys and left untouched;
+// use SyncIndexes to also replace changed and drop removed indexes.
 //
 // Example:
 //
 //	err := db.Indexes(ctx, &User{}, &Product{})
 func (d *Database) Indexes(ctx context.Context, models ...any) error {
-	for _, model := range models {
-		name, indexInfo := ParseModelIndexes(model)
-		if name == "" {
-			return ErrInvalidModelName
-		}
-		if len(indexInfo) == 0 {
-			continue
-		}
-
-		collection := d.Collection(name)
-		indexView := collection.Indexes()
-
-		// Get existing indexes
-		existingIndexes, err := indexView.List(ctx)
-		if err != nil {
+	plans, err := d.PlanIndexes(ctx, models...)
+	if err != nil {
+		return err
+	}
+	for _, plan := range plans {
+		if err := d.ApplyIndexPlan(ctx, &IndexPlan{Collection: plan.Collection, Create: plan.Create}); err != nil {
 			return err
 		}
-
-		// Create a map of existing index keys for quick lookup
-		existingIndexKeys := make(map[string]struct{})
-		for existingIndexes.Next(ctx) {
-			var indexDoc bson.M
-			if err := existingIndexes.Decode(&indexDoc); err != nil {
-				return err
-			}
-			if keys, ok := indexDoc["key"].(bson.M); ok {
-				// Convert keys to a string representation for comparison
-				keyStr := keysMapToString(keys)
-				existingIndexKeys[keyStr] = struct{}{}
-			}
-		}
-		existingIndexes.Close(ctx)
-
-		// Create indexes that don't exist
-		for groupName, v := range indexInfo {
-			if len(v.Fields) == 0 {
-				continue
-			}
-
-			// Create compound index keys
-			keys := bson.D{}
-			for _, fieldName := range v.Fields {
-				keys = append(keys, bson.E{Key: fieldName, Value: 1})
-			}
-
-			// Check if this index already exists
-			keyStr := keysToString(keys)
-			if _, ok := existingIndexKeys[keyStr]; ok {
-				continue // Skip if index already exists
-			}
-
-			im := mongo.IndexModel{Keys: keys}
-			im.Options = options.Index().SetUnique(v.Unique)
-			if len(v.Fields) > 1 {
-				im.Options.SetName(groupName)
-			}
-
-			// Create the index
-			_, err := indexView.CreateOne(ctx, im)
-			if err != nil {
-				return err
-			}
-		}
 	}
 	return nil
 }
@@ -149,7 +149,12 @@ func (d *Database) Indexes(ctx context.Context, models ...any) error {
 //	    log.Fatal(err)
 //	}
 func (d *Database) Set(record any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.SetCtx(context.Background(), record)
+}
+
+// SetCtx is like Set but uses the caller's context for cancellation and deadlines.
+func (d *Database) SetCtx(ctx context.Context, record any) error {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
 	return d.Txn(ctx, func(txn *Txn) error {
@@ -163,7 +168,12 @@ func (d *Database) Set(record any) error {
 //
 //	err := db.Delete(&User{}, "user123")
 func (d *Database) Delete(model any, id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.DeleteCtx(context.Background(), model, id)
+}
+
+// DeleteCtx is like Delete but uses the caller's context for cancellation and deadlines.
+func (d *Database) DeleteCtx(ctx context.Context, model any, id string) error {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
 	return d.Txn(ctx, func(txn *Txn) error {
@@ -187,15 +197,17 @@ func (d *Database) Delete(model any, id string) error {
 //	    }
 //	}
 func (d *Database) Update(record any) (newRecord M, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.UpdateCtx(context.Background(), record)
+}
+
+// UpdateCtx is like Update but uses the caller's context for cancellation and deadlines.
+func (d *Database) UpdateCtx(ctx context.Context, record any) (newRecord M, err error) {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
-	d.Txn(ctx, func(txn *Txn) error {
-		newRecord, err = txn.Model(record).Update(record)
-		return err
+	return TxnResult(ctx, d, func(txn *Txn) (M, error) {
+		return txn.Model(record).Update(record)
 	})
-
-	return
 }
 
 // Pagination retrieves paginated results with total count.
@@ -211,15 +223,23 @@ func (d *Database) Update(record any) (newRecord M, err error) {
 //	}
 //	fmt.Printf("Found %d users, showing page 1\n", total)
 func (d *Database) Pagination(model, filter, sort any, page, pageSize int64, projection ...any) (total int64, list []M, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.PaginationCtx(context.Background(), model, filter, sort, page, pageSize, projection...)
+}
+
+// PaginationCtx is like Pagination but uses the caller's context for cancellation and deadlines.
+func (d *Database) PaginationCtx(ctx context.Context, model, filter, sort any, page, pageSize int64, projection ...any) (total int64, list []M, err error) {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
-	d.Txn(ctx, func(txn *Txn) error {
-		total, list, err = txn.Model(model).Pagination(filter, sort, page, pageSize, projection...)
-		return err
+	type result struct {
+		total int64
+		list  []M
+	}
+	res, err := TxnResult(ctx, d, func(txn *Txn) (result, error) {
+		total, list, err := txn.Model(model).Pagination(filter, sort, page, pageSize, projection...)
+		return result{total: total, list: list}, err
 	})
-
-	return
+	return res.total, res.list, err
 }
 
 // Unmarshal retrieves a record by ID and unmarshals it into the provided model.
@@ -230,7 +250,12 @@ func (d *Database) Pagination(model, filter, sort any, page, pageSize int64, pro
 //	var user User
 //	err := db.Unmarshal("user123", &user)
 func (d *Database) Unmarshal(id, model any, projection ...any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.UnmarshalCtx(context.Background(), id, model, projection...)
+}
+
+// UnmarshalCtx is like Unmarshal but uses the caller's context for cancellation and deadlines.
+func (d *Database) UnmarshalCtx(ctx context.Context, id, model any, projection ...any) error {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
 	return d.Txn(ctx, func(txn *Txn) error {
@@ -245,15 +270,17 @@ func (d *Database) Unmarshal(id, model any, projection ...any) error {
 //
 //	record, err := db.First(&User{}, filter, sort)
 func (d *Database) First(model, filter, sort any, projection ...any) (record M, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.FirstCtx(context.Background(), model, filter, sort, projection...)
+}
+
+// FirstCtx is like First but uses the caller's context for cancellation and deadlines.
+func (d *Database) FirstCtx(ctx context.Context, model, filter, sort any, projection ...any) (record M, err error) {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
-	d.Txn(ctx, func(txn *Txn) error {
-		record, err = txn.Model(model).First(filter, sort, projection...)
-		return err
+	return TxnResult(ctx, d, func(txn *Txn) (M, error) {
+		return txn.Model(model).First(filter, sort, projection...)
 	})
-
-	return
 }
 
 // Count returns the number of documents matching the filter.
@@ -263,15 +290,17 @@ func (d *Database) First(model, filter, sort any, projection ...any) (record M,
 //
 //	count, err := db.Count(&User{}, filter)
 func (d *Database) Count(model, filter any) (count int64, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.CountCtx(context.Background(), model, filter)
+}
+
+// CountCtx is like Count but uses the caller's context for cancellation and deadlines.
+func (d *Database) CountCtx(ctx context.Context, model, filter any) (count int64, err error) {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
-	d.Txn(ctx, func(txn *Txn) error {
-		count, err = txn.Model(model).Count(filter)
-		return err
+	return TxnResult(ctx, d, func(txn *Txn) (int64, error) {
+		return txn.Model(model).Count(filter)
 	})
-
-	return
 }
 
 // Has checks if a record with the given ID exists in the database.
@@ -280,15 +309,17 @@ func (d *Database) Count(model, filter any) (count int64, err error) {
 //
 //	exists, err := db.Has(&User{}, "user123")
 func (d *Database) Has(model, id any) (exists bool, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return d.HasCtx(context.Background(), model, id)
+}
+
+// HasCtx is like Has but uses the caller's context for cancellation and deadlines.
+func (d *Database) HasCtx(ctx context.Context, model, id any) (exists bool, err error) {
+	ctx, cancel := d.withTimeout(ctx)
 	defer cancel()
 
-	d.Txn(ctx, func(txn *Txn) error {
-		exists, err = txn.Model(model).Has(id)
-		return err
+	return TxnResult(ctx, d, func(txn *Txn) (bool, error) {
+		return txn.Model(model).Has(id)
 	})
-
-	return
 }
 
 // List iterates over documents matching the filter using a callback function.
@@ -306,23 +337,3 @@ func (d *Database) List(ctx context.Context, model any, filter M, cb func(m M) (
 		return txn.Model(model).List(filter, cb, projection...)
 	})
 }
-
-// keysToString converts bson.D to string for index key comparison.
-// Used internally for checking if indexes already exist.
-func keysToString(keys bson.D) string {
-	var parts []string
-	for _, key := range keys {
-		parts = append(parts, key.Key+":"+fmt.Sprintf("%v", key.Value))
-	}
-	return strings.Join(parts, ",")
-}
-
-// keysMapToString converts bson.M to string for index key comparison.
-// Used internally for checking if indexes already exist.
-func keysMapToString(keys bson.M) string {
-	var parts []string
-	for key, value := range keys {
-		parts = append(parts, key+":"+fmt.Sprintf("%v", value))
-	}
-	return strings.Join(parts, ",")
-}
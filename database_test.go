@@ -403,3 +403,70 @@ func TestNext(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCtxHelpers(t *testing.T) {
+	type Account struct {
+		ID   string `bson:"_id"`
+		Name string `bson:"name"`
+	}
+
+	db := NewMemoryDatabase("test")
+	var deadlines []time.Time
+	db.Use(func(next Handler) Handler {
+		return func(op *Operation) (any, error) {
+			deadline, ok := op.Ctx.Deadline()
+			require.True(t, ok, op.Name)
+			deadlines = append(deadlines, deadline)
+			return next(op)
+		}
+	})
+	account := &Account{ID: "1", Name: "a"}
+
+	calls := func(ctx context.Context) []error {
+		err0 := db.SetCtx(ctx, account)
+		_, err1 := db.UpdateCtx(ctx, account)
+		_, _, err2 := db.PaginationCtx(ctx, &Account{}, nil, nil, 1, 10)
+		_, err3 := db.FirstCtx(ctx, &Account{}, nil, nil)
+		_, err4 := db.CountCtx(ctx, &Account{}, Map().Set("name", "a"))
+		_, err5 := db.HasCtx(ctx, &Account{}, "1")
+		return []error{
+			err0, err1, err2, err3, err4, err5,
+			db.UnmarshalCtx(ctx, "1", &Account{}), db.DeleteCtx(ctx, &Account{}, "1"),
+		}
+	}
+
+	// Timeout applies when the context has no deadline
+	db.Timeout = time.Hour
+	start := time.Now()
+	for _, err := range calls(context.Background()) {
+		require.NoError(t, err)
+	}
+	require.Len(t, deadlines, 8)
+	for _, deadline := range deadlines {
+		require.WithinDuration(t, start.Add(time.Hour), deadline, time.Minute)
+	}
+
+	// the caller's deadline is kept
+	deadlines = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	for _, err := range calls(ctx) {
+		require.NoError(t, err)
+	}
+	for _, deadline := range deadlines {
+		require.Equal(t, want, deadline)
+	}
+
+	// a cancelled or expired context fails every helper
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range calls(cancelled) {
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	for _, err := range calls(expired) {
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+}
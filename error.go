@@ -22,6 +22,18 @@ var (
 
 	// ErrDuplicateKey is returned when a unique constraint violation occurs.
 	ErrDuplicateKey = errors.New("duplicate key error")
+
+	// ErrNotSupported is returned when the storage backend cannot perform an operation,
+	// such as change streams on the in-memory backend.
+	ErrNotSupported = errors.New("operation not supported by the backend")
+
+	// ErrVersionConflict is returned when a record tagged with a db:"version" field
+	// was changed by someone else since it was read.
+	ErrVersionConflict = errors.New("version conflict")
+
+	// ErrInvalidCursor is returned when a PageByCursor cursor is malformed, was signed with
+	// another secret or was created for a different sort.
+	ErrInvalidCursor = errors.New("invalid cursor")
 )
 
 // isDuplicateKeyError checks if the error is a MongoDB duplicate key error.
@@ -55,3 +67,9 @@ func isDuplicateKeyError(err error) bool {
 		strings.Contains(errMsg, "e11000") ||
 		strings.Contains(errMsg, "index:") && strings.Contains(errMsg, "dup key")
 }
+
+// isIDConflictError checks if the error is a duplicate key error on the _id index,
+// as returned by an upsert whose filter didn't match an existing document.
+func isIDConflictError(err error) bool {
+	return isDuplicateKeyError(err) && strings.Contains(err.Error(), "index: _id_ ")
+}
@@ -0,0 +1,168 @@
+// Package mongo provides lifecycle hooks that records can implement.
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BeforeSaver is implemented by records that validate or prepare themselves before Model.Set.
+// An error cancels the write.
+type BeforeSaver interface {
+	BeforeSave(ctx context.Context) error
+}
+
+// AfterSaver is implemented by records that act after a successful Model.Set or Model.Update.
+type AfterSaver interface {
+	AfterSave(ctx context.Context) error
+}
+
+// BeforeUpdater is implemented by records that validate or prepare themselves before Model.Update.
+// An error cancels the write.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// BeforeDeleter is implemented by records that check a delete before Model.Del.
+// The stored record is loaded to run the hook; an error cancels the delete.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterFinder is implemented by records that post-process themselves after being read
+// by Get, Unmarshal, First, Find, Pagination, Next and the list methods.
+type AfterFinder interface {
+	AfterFind(ctx context.Context) error
+}
+
+var (
+	beforeDeleterType = reflect.TypeOf((*BeforeDeleter)(nil)).Elem()
+	afterFinderType   = reflect.TypeOf((*AfterFinder)(nil)).Elem()
+)
+
+// beforeSave runs the BeforeSave hook of record, if any.
+func (m *Model) beforeSave(record any) error {
+	if h, ok := record.(BeforeSaver); ok {
+		return h.BeforeSave(m.txn.ctx)
+	}
+	return nil
+}
+
+// afterSave runs the AfterSave hook of record, if any.
+func (m *Model) afterSave(record any) error {
+	if h, ok := record.(AfterSaver); ok {
+		return h.AfterSave(m.txn.ctx)
+	}
+	return nil
+}
+
+// beforeUpdate runs the BeforeUpdate hook of record, if any.
+func (m *Model) beforeUpdate(record any) error {
+	if h, ok := record.(BeforeUpdater); ok {
+		return h.BeforeUpdate(m.txn.ctx)
+	}
+	return nil
+}
+
+// beforeDelete loads the record matching filter and runs its BeforeDelete hook,
+// if the model type has one. A missing record runs no hook.
+func (m *Model) beforeDelete(filter any) error {
+	if !m.schema.beforeDelete {
+		return nil
+	}
+
+	record := reflect.New(m.schema.typ).Interface()
+	err := m.coll.FindOne(m.txn.ctx, m.scoped(filter)).Decode(record)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}
+	return record.(BeforeDeleter).BeforeDelete(m.txn.ctx)
+}
+
+// afterFind runs the AfterFind hook of the model type on a document read as M.
+// The document is decoded into the model type and passed to the hook. Only the fields the hook
+// changed are written back, so fields left out by a projection or not declared by the type are kept as read.
+func (m *Model) afterFind(doc M) (M, error) {
+	if !m.schema.afterFind || m.skipAfterFind || doc == nil {
+		return doc, nil
+	}
+
+	record := reflect.New(m.schema.typ).Interface()
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := bson.Unmarshal(raw, record); err != nil {
+		return nil, err
+	}
+	before, err := toMap(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := record.(AfterFinder).AfterFind(m.txn.ctx); err != nil {
+		return nil, err
+	}
+	after, err := toMap(record)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(M, len(doc))
+	for k, v := range doc {
+		out[k] = v
+	}
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			out[k] = v
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			delete(out, k)
+		}
+	}
+	return out, nil
+}
+
+// toMap encodes record as M.
+func toMap(record any) (M, error) {
+	raw, err := bson.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	out := Map()
+	if err := bson.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// afterFindAll runs afterFind on each document of list.
+func (m *Model) afterFindAll(list []M) ([]M, error) {
+	if !m.schema.afterFind || m.skipAfterFind {
+		return list, nil
+	}
+	for i, doc := range list {
+		out, err := m.afterFind(doc)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = out
+	}
+	return list, nil
+}
+
+// runAfterFind runs the AfterFind hook of a decoded record, if any.
+func runAfterFind(ctx context.Context, record any) error {
+	if h, ok := record.(AfterFinder); ok {
+		return h.AfterFind(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+type hookUser struct {
+	ID      string `bson:"_id"`
+	Name    string `bson:"name"`
+	Slug    string `bson:"slug"`
+	Locked  bool   `bson:"locked"`
+	Display string `bson:"-"`
+}
+
+func TestMemoryHooks(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	require.NoError(t, db.Set(&hookUser{ID: "1", Name: "Alice"}))
+	require.EqualError(t, db.Set(&hookUser{ID: "2"}), "name required")
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		users := mongo.NewCollection[hookUser](txn)
+		user, err := users.Get("1")
+		require.NoError(t, err)
+		require.Equal(t, "alice", user.Slug)
+		require.Equal(t, "@alice", user.Display)
+
+		list, err := users.Find(nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, "@alice", list[0].Display)
+
+		record := &hookUser{}
+		require.NoError(t, txn.Model(&hookUser{}).Unmarshal("1", record))
+		require.Equal(t, "@alice", record.Display)
+
+		_, err = users.Update(&hookUser{ID: "1", Name: "ALICIA", Locked: true})
+		require.NoError(t, err)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// a failing hook aborts the multi-document transaction
+	err = db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&hookUser{})
+		if err := model.Set(&hookUser{ID: "3", Name: "Bob"}); err != nil {
+			return err
+		}
+		return model.Del("1")
+	}, true)
+	require.EqualError(t, err, "locked")
+
+	has, err := db.Has(&hookUser{}, "3")
+	require.NoError(t, err)
+	require.False(t, has)
+	has, err = db.Has(&hookUser{}, "1")
+	require.NoError(t, err)
+	require.True(t, has)
+}
+
+type labelUser struct {
+	ID    string `bson:"_id"`
+	Name  string `bson:"name"`
+	Age   int    `bson:"age"`
+	Label string `bson:"label,omitempty"`
+}
+
+func (u *labelUser) AfterFind(ctx context.Context) error {
+	u.Label = "user:" + u.Name
+	return nil
+}
+
+func TestMemoryAfterFindProjection(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&labelUser{})
+		require.NoError(t, model.Set(mongo.Map().Set("_id", "1").Set("name", "n").Set("age", 3).Set("extra", "x")))
+
+		// excluded fields aren't added back as zero values and the hook's changes are kept
+		doc, err := model.Get("1", mongo.Include("name"))
+		require.NoError(t, err)
+		require.Equal(t, mongo.Map().Set("_id", "1").Set("name", "n").Set("label", "user:n"), doc)
+
+		// fields the type doesn't declare are kept
+		doc, err = model.Get("1")
+		require.NoError(t, err)
+		require.Equal(t, "x", doc["extra"])
+		require.EqualValues(t, 3, doc["age"])
+		require.Equal(t, "user:n", doc["label"])
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func (u *hookUser) BeforeSave(ctx context.Context) error {
+	if u.Name == "" {
+		return errors.New("name required")
+	}
+	u.Slug = strings.ToLower(u.Name)
+	return nil
+}
+
+func (u *hookUser) BeforeUpdate(ctx context.Context) error {
+	return u.BeforeSave(ctx)
+}
+
+func (u *hookUser) BeforeDelete(ctx context.Context) error {
+	if u.Locked {
+		return errors.New("locked")
+	}
+	return nil
+}
+
+func (u *hookUser) AfterFind(ctx context.Context) error {
+	u.Display = "@" + u.Slug
+	return nil
+}
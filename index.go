@@ -0,0 +1,430 @@
+// Package mongo provides index planning and reconciliation from struct tags.
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexSpec describes an index by its ordered keys and options.
+type IndexSpec struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	Sparse bool
+
+	// Partial restricts the index to documents matching the filter.
+	Partial bson.D
+
+	// ExpireAfterSeconds makes a TTL index. Nil means no expiry.
+	ExpireAfterSeconds *int32
+
+	// Collation is the collation locale, empty for the simple binary comparison.
+	Collation string
+
+	// Weights holds the weight of text fields; missing fields weigh 1.
+	Weights map[string]int32
+}
+
+// sameKeys reports whether both specs index the same fields in the same order and direction.
+// The fields of a text index are compared as a set, since the server doesn't keep their order.
+func (s IndexSpec) sameKeys(other IndexSpec) bool {
+	a, err := toDocument(canonicalKeys(s.Keys))
+	if err != nil {
+		return false
+	}
+	b, err := toDocument(canonicalKeys(other.Keys))
+	if err != nil {
+		return false
+	}
+	return valuesEqual(a, b)
+}
+
+// sameOptions reports whether both specs have the same index options.
+func (s IndexSpec) sameOptions(other IndexSpec) bool {
+	if s.Unique != other.Unique || s.Sparse != other.Sparse || s.Collation != other.Collation {
+		return false
+	}
+	if (s.ExpireAfterSeconds == nil) != (other.ExpireAfterSeconds == nil) ||
+		s.ExpireAfterSeconds != nil && *s.ExpireAfterSeconds != *other.ExpireAfterSeconds {
+		return false
+	}
+	if len(s.Partial) > 0 || len(other.Partial) > 0 {
+		a, err := toDocument(s.Partial)
+		if err != nil {
+			return false
+		}
+		b, err := toDocument(other.Partial)
+		if err != nil || !valuesEqual(a, b) {
+			return false
+		}
+	}
+	for _, k := range s.Keys {
+		if k.Value == "text" && s.weight(k.Key) != other.weight(k.Key) {
+			return false
+		}
+	}
+	return true
+}
+
+// weight returns the weight of a text field.
+func (s IndexSpec) weight(field string) int32 {
+	if w, ok := s.Weights[field]; ok {
+		return w
+	}
+	return 1
+}
+
+// options returns the driver options creating the index.
+func (s IndexSpec) options() *options.IndexOptions {
+	opt := options.Index().SetName(s.Name)
+	if s.Unique {
+		opt.SetUnique(true)
+	}
+	if s.Sparse {
+		opt.SetSparse(true)
+	}
+	if len(s.Partial) > 0 {
+		opt.SetPartialFilterExpression(s.Partial)
+	}
+	if s.ExpireAfterSeconds != nil {
+		opt.SetExpireAfterSeconds(*s.ExpireAfterSeconds)
+	}
+	if s.Collation != "" {
+		opt.SetCollation(&options.Collation{Locale: s.Collation})
+	}
+	if len(s.Weights) > 0 {
+		opt.SetWeights(s.Weights)
+	}
+	return opt
+}
+
+// canonicalKeys sorts the text fields of keys by name.
+func canonicalKeys(keys bson.D) bson.D {
+	var text []string
+	out := make(bson.D, 0, len(keys))
+	for _, k := range keys {
+		if k.Value == "text" {
+			if text == nil {
+				// placeholder where the text fields start
+				out = append(out, bson.E{Key: "", Value: "text"})
+			}
+			text = append(text, k.Key)
+			continue
+		}
+		out = append(out, k)
+	}
+	if text == nil {
+		return keys
+	}
+
+	sort.Strings(text)
+	result := make(bson.D, 0, len(keys))
+	for _, k := range out {
+		if k.Key == "" {
+			for _, field := range text {
+				result = append(result, bson.E{Key: field, Value: "text"})
+			}
+			continue
+		}
+		result = append(result, k)
+	}
+	return result
+}
+
+// IndexManager lists, creates and drops the indexes of a collection.
+type IndexManager interface {
+	List(ctx context.Context) ([]IndexSpec, error)
+	Create(ctx context.Context, spec IndexSpec) error
+	Drop(ctx context.Context, name string) error
+}
+
+// IndexReplacement is an existing index whose options differ from the declared ones.
+type IndexReplacement struct {
+	Old IndexSpec
+	New IndexSpec
+}
+
+// IndexPlan lists the changes needed for a collection's indexes to match its model tags.
+type IndexPlan struct {
+	Collection string
+
+	// Create lists declared indexes that don't exist.
+	Create []IndexSpec
+
+	// Drop lists existing indexes that are no longer declared. The _id_ index is never dropped.
+	Drop []IndexSpec
+
+	// Replace lists indexes with the declared keys but different options, such as a changed unique flag.
+	Replace []IndexReplacement
+}
+
+// Empty reports whether the plan has no changes.
+func (p *IndexPlan) Empty() bool {
+	return len(p.Create) == 0 && len(p.Drop) == 0 && len(p.Replace) == 0
+}
+
+// String summarizes the plan, one change per line.
+func (p *IndexPlan) String() string {
+	var sb strings.Builder
+	for _, v := range p.Drop {
+		fmt.Fprintf(&sb, "%s: drop %s %v\n", p.Collection, v.Name, v.Keys)
+	}
+	for _, v := range p.Replace {
+		fmt.Fprintf(&sb, "%s: replace %s %v -> %s %v\n", p.Collection, v.Old.Name, v.Old.Keys, v.New.Name, v.New.Keys)
+	}
+	for _, v := range p.Create {
+		fmt.Fprintf(&sb, "%s: create %s %v\n", p.Collection, v.Name, v.Keys)
+	}
+	return sb.String()
+}
+
+// ModelIndexSpecs returns the collection name and the indexes declared by the model's struct tags.
+// Unlike ParseModelIndexes the result is stable: specs are sorted by group name and keys follow
+// the positions given in the tags. Compound indexes are named after their group, single field
+// indexes get MongoDB's default name.
+func ModelIndexSpecs(model any) (string, []IndexSpec, error) {
+	name, indexInfo := ParseModelIndexes(model)
+
+	groups := make([]string, 0, len(indexInfo))
+	for groupName := range indexInfo {
+		groups = append(groups, groupName)
+	}
+	sort.Strings(groups)
+
+	specs := make([]IndexSpec, 0, len(groups))
+	for _, groupName := range groups {
+		v := indexInfo[groupName]
+		if len(v.Fields) == 0 {
+			continue
+		}
+
+		positions := make(map[int]string)
+		for i, position := range v.Positions {
+			if position == 0 {
+				continue
+			}
+			if other, ok := positions[position]; ok {
+				return name, nil, fmt.Errorf("index [%s]: fields %s and %s share position %d", groupName, other, v.Fields[i], position)
+			}
+			positions[position] = v.Fields[i]
+		}
+
+		keys := v.Keys()
+		spec := IndexSpec{
+			Name:      defaultIndexName(keys),
+			Keys:      keys,
+			Unique:    v.Unique,
+			Sparse:    v.Sparse,
+			Collation: v.Collation,
+			Weights:   v.Weights,
+		}
+		if len(v.Fields) > 1 {
+			spec.Name = groupName
+		}
+		if v.Partial != "" {
+			if err := bson.UnmarshalExtJSON([]byte(v.Partial), false, &spec.Partial); err != nil {
+				return name, nil, errors.Wrapf(err, "partial filter of index [%s]", groupName)
+			}
+		}
+		if v.TTL != nil {
+			seconds := int32(v.TTL.Seconds())
+			spec.ExpireAfterSeconds = &seconds
+		}
+		specs = append(specs, spec)
+	}
+	return name, specs, nil
+}
+
+// defaultIndexName returns the name MongoDB generates for keys, e.g. "name_1_age_-1".
+func defaultIndexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		parts = append(parts, k.Key, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// PlanIndexes compares the indexes declared by the models with the existing ones without changing anything.
+// Indexes are matched by their ordered keys; option changes are planned as replacements.
+//
+// Example:
+//
+//	plans, err := db.PlanIndexes(ctx, &User{}, &Job{})
+//	for _, plan := range plans {
+//	    fmt.Print(plan)
+//	}
+func (d *Database) PlanIndexes(ctx context.Context, models ...any) ([]*IndexPlan, error) {
+	plans := make([]*IndexPlan, 0, len(models))
+	for _, model := range models {
+		name, declared, err := ModelIndexSpecs(model)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, ErrInvalidModelName
+		}
+
+		existing, err := d.backend().Indexes(name).List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		plan := &IndexPlan{Collection: name}
+		matched := make([]bool, len(existing))
+		for _, want := range declared {
+			found := false
+			for i, have := range existing {
+				if matched[i] || !have.sameKeys(want) {
+					continue
+				}
+				matched[i] = true
+				found = true
+				if !have.sameOptions(want) {
+					plan.Replace = append(plan.Replace, IndexReplacement{Old: have, New: want})
+				}
+				break
+			}
+			if !found {
+				plan.Create = append(plan.Create, want)
+			}
+		}
+
+		for i, have := range existing {
+			if !matched[i] && have.Name != "_id_" {
+				plan.Drop = append(plan.Drop, have)
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// ApplyIndexPlan executes a plan: drops first, then replacements, then creations.
+func (d *Database) ApplyIndexPlan(ctx context.Context, plan *IndexPlan) error {
+	indexes := d.backend().Indexes(plan.Collection)
+	for _, v := range plan.Drop {
+		if v.Name == "_id_" {
+			continue
+		}
+		if err := indexes.Drop(ctx, v.Name); err != nil {
+			return err
+		}
+	}
+	for _, v := range plan.Replace {
+		if v.Old.Name != "_id_" {
+			if err := indexes.Drop(ctx, v.Old.Name); err != nil {
+				return err
+			}
+		}
+		if err := indexes.Create(ctx, v.New); err != nil {
+			return err
+		}
+	}
+	for _, v := range plan.Create {
+		if err := indexes.Create(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncIndexes makes the indexes of each model match its struct tags, creating, replacing and
+// dropping indexes as needed, and returns the applied plans. Use PlanIndexes for a dry run.
+func (d *Database) SyncIndexes(ctx context.Context, models ...any) ([]*IndexPlan, error) {
+	plans, err := d.PlanIndexes(ctx, models...)
+	if err != nil {
+		return nil, err
+	}
+	for _, plan := range plans {
+		if err := d.ApplyIndexPlan(ctx, plan); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+// driverIndexes manages indexes through the driver's IndexView.
+type driverIndexes struct {
+	view mongo.IndexView
+}
+
+func (x driverIndexes) List(ctx context.Context) ([]IndexSpec, error) {
+	cursor, err := x.view.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		Name               string             `bson:"name"`
+		Key                bson.D             `bson:"key"`
+		Unique             bool               `bson:"unique"`
+		Sparse             bool               `bson:"sparse"`
+		Partial            bson.D             `bson:"partialFilterExpression"`
+		ExpireAfterSeconds *int32             `bson:"expireAfterSeconds"`
+		Weights            bson.D             `bson:"weights"`
+		Collation          *options.Collation `bson:"collation"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	specs := make([]IndexSpec, 0, len(docs))
+	for _, v := range docs {
+		spec := IndexSpec{
+			Name:               v.Name,
+			Keys:               textKeys(v.Key, v.Weights),
+			Unique:             v.Unique,
+			Sparse:             v.Sparse,
+			Partial:            v.Partial,
+			ExpireAfterSeconds: v.ExpireAfterSeconds,
+		}
+		if v.Collation != nil {
+			spec.Collation = v.Collation.Locale
+		}
+		for _, w := range v.Weights {
+			if spec.Weights == nil {
+				spec.Weights = make(map[string]int32)
+			}
+			if n, ok := w.Value.(int32); ok {
+				spec.Weights[w.Key] = n
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// textKeys replaces the internal _fts and _ftsx keys of a listed text index with its text fields.
+func textKeys(keys, weights bson.D) bson.D {
+	out := make(bson.D, 0, len(keys))
+	for _, k := range keys {
+		switch k.Key {
+		case "_fts":
+			for _, w := range weights {
+				out = append(out, bson.E{Key: w.Key, Value: "text"})
+			}
+		case "_ftsx":
+		default:
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func (x driverIndexes) Create(ctx context.Context, spec IndexSpec) error {
+	_, err := x.view.CreateOne(ctx, mongo.IndexModel{Keys: spec.Keys, Options: spec.options()})
+	return err
+}
+
+func (x driverIndexes) Drop(ctx context.Context, name string) error {
+	_, err := x.view.DropOne(ctx, name)
+	return err
+}
@@ -0,0 +1,114 @@
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMemorySyncIndexes(t *testing.T) {
+	ctx := context.Background()
+	backend := mongo.NewMemoryBackend("test")
+	db := mongo.NewDatabaseWithBackend(backend)
+
+	name, specs, err := mongo.ModelIndexSpecs(&memUser{})
+	require.NoError(t, err)
+	require.Len(t, specs, 2)
+
+	// stale state: age became unique, name lost its index, an old tag was removed
+	indexes := backend.Indexes(name)
+	require.NoError(t, indexes.Create(ctx, mongo.IndexSpec{Name: "age_1", Keys: bson.D{{Key: "age", Value: 1}}, Unique: true}))
+	require.NoError(t, indexes.Create(ctx, mongo.IndexSpec{Name: "legacy_1", Keys: bson.D{{Key: "legacy", Value: 1}}}))
+
+	plans, err := db.PlanIndexes(ctx, &memUser{})
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	plan := plans[0]
+	require.Equal(t, name, plan.Collection)
+	require.Len(t, plan.Create, 1)
+	require.Equal(t, "name_1", plan.Create[0].Name)
+	require.Len(t, plan.Replace, 1)
+	require.False(t, plan.Replace[0].New.Unique)
+	require.Len(t, plan.Drop, 1)
+	require.Equal(t, "legacy_1", plan.Drop[0].Name)
+
+	// dry run leaves the indexes untouched
+	list, err := indexes.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	// Indexes only creates what is missing
+	require.NoError(t, db.Indexes(ctx, &memUser{}))
+	plans, err = db.PlanIndexes(ctx, &memUser{})
+	require.NoError(t, err)
+	require.Empty(t, plans[0].Create)
+	require.Len(t, plans[0].Replace, 1)
+
+	_, err = db.SyncIndexes(ctx, &memUser{})
+	require.NoError(t, err)
+	plans, err = db.PlanIndexes(ctx, &memUser{})
+	require.NoError(t, err)
+	require.True(t, plans[0].Empty())
+
+	list, err = indexes.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "_id_", list[0].Name)
+	require.Len(t, list, 3)
+
+	// the reconciled unique index is enforced
+	require.NoError(t, db.Set(&memUser{ID: "1", Name: "a", Age: 1}))
+	require.NoError(t, db.Set(&memUser{ID: "2", Name: "b", Age: 1}))
+	require.ErrorIs(t, db.Set(&memUser{ID: "3", Name: "a"}), mongo.ErrDuplicateKey)
+}
+
+func TestMemoryIndexOptions(t *testing.T) {
+	type Place struct {
+		ID       string     `bson:"_id"`
+		Code     string     `bson:"code,omitempty" db:"unique,sparse"`
+		Owner    string     `bson:"owner" db:"unique=owner_live,partial={\"live\":true}"`
+		Live     bool       `bson:"live"`
+		Rank     int64      `bson:"rank" db:"index=-"`
+		Title    string     `bson:"title" db:"text=10,index=search"`
+		Body     string     `bson:"body" db:"text,index=search"`
+		Hash     string     `bson:"hash" db:"hashed"`
+		Location bson.M     `bson:"location" db:"2dsphere"`
+		Expires  *time.Time `bson:"expires" db:"index,ttl=1h,collation=en"`
+	}
+
+	_, specs, err := mongo.ModelIndexSpecs(&Place{})
+	require.NoError(t, err)
+	byName := map[string]mongo.IndexSpec{}
+	for _, spec := range specs {
+		byName[spec.Name] = spec
+	}
+	require.True(t, byName["code_1"].Sparse)
+	require.Equal(t, bson.D{{Key: "live", Value: true}}, byName["owner_1"].Partial)
+	require.Contains(t, byName, "rank_-1")
+	require.Equal(t, bson.D{{Key: "title", Value: "text"}, {Key: "body", Value: "text"}}, byName["search"].Keys)
+	require.Equal(t, map[string]int32{"title": 10}, byName["search"].Weights)
+	require.Contains(t, byName, "hash_hashed")
+	require.Contains(t, byName, "location_2dsphere")
+	require.Equal(t, int32(3600), *byName["expires_1"].ExpireAfterSeconds)
+	require.Equal(t, "en", byName["expires_1"].Collation)
+
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	require.NoError(t, db.Indexes(ctx, &Place{}))
+
+	// sparse: documents without a code don't collide
+	require.NoError(t, db.Set(&Place{ID: "1", Owner: "a"}))
+	require.NoError(t, db.Set(&Place{ID: "2", Owner: "a"}))
+	require.NoError(t, db.Set(&Place{ID: "3", Code: "x", Owner: "b", Live: true}))
+	require.ErrorIs(t, db.Set(&Place{ID: "4", Code: "x"}), mongo.ErrDuplicateKey)
+
+	// partial: only live documents must have a unique owner
+	require.ErrorIs(t, db.Set(&Place{ID: "5", Owner: "b", Live: true}), mongo.ErrDuplicateKey)
+
+	plans, err := db.PlanIndexes(ctx, &Place{})
+	require.NoError(t, err)
+	require.True(t, plans[0].Empty(), plans[0].String())
+}
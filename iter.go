@@ -0,0 +1,191 @@
+// Package mongo provides range-over-func iterators over a model collection.
+package mongo
+
+import (
+	"iter"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IterOptions configures Model.All and Iter.
+type IterOptions struct {
+	// Desc iterates in descending _id order.
+	Desc bool
+
+	// BatchSize is the number of documents fetched per page, 100 by default.
+	BatchSize int
+
+	// Projection limits the returned fields.
+	Projection any
+}
+
+// All returns an iterator over the documents matching the filter in _id order.
+// Documents are fetched in batches with the same keyset pagination as ListByCursor, each batch
+// passing through the middleware chain as a "List" operation. Breaking out of the loop stops
+// fetching, and the iteration ends with the context's error when the transaction context is done.
+// The first error is yielded with a nil document and ends the iteration.
+//
+// Example:
+//
+//	for user, err := range txn.Model(&User{}).All(filter) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    fmt.Println(user["name"])
+//	}
+func (m *Model) All(filter M, opts ...func(o *IterOptions)) iter.Seq2[M, error] {
+	opt := &IterOptions{}
+	for _, v := range opts {
+		v(opt)
+	}
+	return func(yield func(M, error) bool) {
+		m.scan(filter, opt, func(doc M, _ any, err error) bool {
+			return yield(doc, err)
+		})
+	}
+}
+
+// scan walks the documents matching the filter in _id batches and passes every document
+// to yield with the value to continue after it, see keysetID. The first error is passed
+// with a nil document and ends the scan, as does yield returning false.
+func (m *Model) scan(filter M, opt *IterOptions, yield func(doc M, id any, err error) bool) {
+	limit := int64(opt.BatchSize)
+	if limit < 1 {
+		limit = defaultListLimit
+	}
+
+	sortOrder := 1
+	cmpOp := "$gt"
+	if opt.Desc {
+		sortOrder = -1
+		cmpOp = "$lt"
+	}
+
+	ctx := m.txn.ctx
+	nextFilter := Map()
+	for k, v := range filter {
+		nextFilter[k] = v
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			yield(nil, nil, err)
+			return
+		}
+
+		var ids []bson.RawValue
+		op := &Operation{
+			Name:       "List",
+			Filter:     nextFilter,
+			Sort:       Map().Set("_id", sortOrder),
+			Projection: opt.Projection,
+			Options:    Map().Set("limit", limit),
+		}
+		list, err := run(m, op, func(m *Model, op *Operation) (list []M, err error) {
+			findOpt := options.Find().SetLimit(limit).SetSort(op.Sort)
+			if op.Projection != nil {
+				findOpt.SetProjection(op.Projection)
+			}
+			list, ids, err = m.findWithIDs(op.Filter, findOpt)
+			return list, err
+		})
+		if err != nil {
+			yield(nil, nil, err)
+			return
+		}
+
+		// a middleware may have replaced the result, so only trust ids fetched with it
+		trusted := len(ids) == len(list)
+		var last any
+		for i, doc := range list {
+			if err := ctx.Err(); err != nil {
+				yield(nil, nil, err)
+				return
+			}
+			if trusted {
+				last = keysetID(ids[i])
+			} else {
+				last, _ = doc.Get("_id")
+			}
+			if !yield(doc, last, nil) {
+				return
+			}
+		}
+
+		if last == nil || int64(len(list)) < limit {
+			return
+		}
+
+		nextFilter = Map()
+		for k, v := range filter {
+			nextFilter[k] = v
+		}
+		nextFilter.Set("_id", Map().Set(cmpOp, last))
+	}
+}
+
+// Iter returns an iterator over the documents matching the filter decoded as *T.
+// It walks the collection like Model.All; a document that fails to decode ends the iteration with its error.
+//
+// Example:
+//
+//	for user, err := range mongo.Iter[User](txn.Model(&User{}), nil) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    fmt.Println(user.Name)
+//	}
+func Iter[T any](m *Model, filter M, opts ...func(o *IterOptions)) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for doc, err := range m.All(filter, opts...) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			item, err := Decode[T](doc)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// findWithIDs is find that also returns the _id of every document as stored,
+// keeping the field order of composite ids that decoding into M loses.
+func (m *Model) findWithIDs(filter any, opt *options.FindOptions) (list []M, ids []bson.RawValue, err error) {
+	cursor, err := m.coll.Find(m.txn.ctx, m.scoped(filter), opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(m.txn.ctx)
+
+	for cursor.Next(m.txn.ctx) {
+		doc := M{}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, nil, err
+		}
+		list = append(list, doc)
+		ids = append(ids, cursor.Current.Lookup("_id"))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	list, err = m.afterFindAll(list)
+	return list, ids, err
+}
+
+// keysetID returns the value to continue a keyset iteration after id.
+// Strings are kept as is; other types, such as ObjectIDs, numbers, binary UUIDs and
+// composite documents, are compared as their raw BSON value.
+func keysetID(id bson.RawValue) any {
+	if s, ok := id.StringValueOK(); ok {
+		return s
+	}
+	return id
+}
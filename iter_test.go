@@ -0,0 +1,126 @@
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoryIterators(t *testing.T) {
+	db := mongo.NewMemoryDatabase("test")
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, db.Set(&memUser{ID: id, Name: id}))
+	}
+
+	var fetches int
+	db.Use(func(next mongo.Handler) mongo.Handler {
+		return func(op *mongo.Operation) (any, error) {
+			if op.Name == "List" {
+				fetches++
+			}
+			return next(op)
+		}
+	})
+	batch := func(o *mongo.IterOptions) { o.BatchSize = 2 }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&memUser{})
+
+		var ids []string
+		for doc, err := range model.All(nil, batch) {
+			require.NoError(t, err)
+			ids = append(ids, doc["_id"].(string))
+			if len(ids) == 3 {
+				break
+			}
+		}
+		require.Equal(t, []string{"a", "b", "c"}, ids)
+		require.Equal(t, 2, fetches)
+
+		ids = nil
+		for user, err := range mongo.Iter[memUser](model, mongo.Map().Set("name", mongo.Map().Set("$ne", "c")), batch, func(o *mongo.IterOptions) {
+			o.Desc = true
+		}) {
+			require.NoError(t, err)
+			ids = append(ids, user.Name)
+		}
+		require.Equal(t, []string{"e", "d", "b", "a"}, ids)
+
+		ids = nil
+		for user, err := range mongo.NewCollection[memUser](txn).All(nil) {
+			require.NoError(t, err)
+			ids = append(ids, user.ID)
+		}
+		require.Len(t, ids, 5)
+
+		// cancelling the context ends the iteration with its error
+		var iterErr error
+		count := 0
+		for _, err := range model.All(nil, batch) {
+			if err != nil {
+				iterErr = err
+				break
+			}
+			count++
+			cancel()
+		}
+		require.Equal(t, 1, count)
+		require.ErrorIs(t, iterErr, context.Canceled)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMemoryListByCursorIDTypes(t *testing.T) {
+	oid := func(i byte) primitive.ObjectID { return primitive.ObjectID{0, 0, 0, i} }
+	uuid := func(i byte) primitive.Binary {
+		return primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: []byte{i, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}
+	}
+	cases := map[string][]any{
+		"string":    {"a", "b", "c", "d", "e"},
+		"object_id": {oid(1), oid(2), oid(3), oid(4), oid(5)},
+		"int64":     {int64(-5), int64(1), int64(2), int64(30), int64(400)},
+		"uuid":      {uuid(1), uuid(2), uuid(3), uuid(4), uuid(5)},
+		"composite": {
+			bson.D{{Key: "z", Value: 1}, {Key: "a", Value: 9}},
+			bson.D{{Key: "z", Value: 2}, {Key: "a", Value: 1}},
+			bson.D{{Key: "z", Value: 2}, {Key: "a", Value: 5}},
+			bson.D{{Key: "z", Value: 3}, {Key: "a", Value: 0}},
+			bson.D{{Key: "z", Value: 4}, {Key: "a", Value: 0}},
+		},
+	}
+
+	for name, ids := range cases {
+		t.Run(name, func(t *testing.T) {
+			db := mongo.NewMemoryDatabase("test")
+			err := db.Txn(context.Background(), func(txn *mongo.Txn) error {
+				model := txn.Model(name)
+				for i := len(ids) - 1; i >= 0; i-- {
+					require.NoError(t, model.Set(mongo.Map().Set("_id", ids[i]).Set("n", i)))
+				}
+
+				for _, desc := range []bool{false, true} {
+					var got []int32
+					err := model.ListByCursor(nil, desc, 2, func(m mongo.M) (bool, error) {
+						got = append(got, m["n"].(int32))
+						return true, nil
+					})
+					require.NoError(t, err)
+					if desc {
+						require.Equal(t, []int32{4, 3, 2, 1, 0}, got)
+					} else {
+						require.Equal(t, []int32{0, 1, 2, 3, 4}, got)
+					}
+				}
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
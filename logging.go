@@ -0,0 +1,281 @@
+// Package mongo provides structured operation logging and a slow operation log based on log/slog.
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// LogOptions configures Logging and CommandMonitor.
+type LogOptions struct {
+	// Level is the level of regular records, slog.LevelInfo by default.
+	// Failed operations are logged at slog.LevelError, except ErrRecordNotFound.
+	Level slog.Level
+
+	// SlowThreshold logs operations lasting at least this long as warnings with their filter and sort.
+	// Zero disables the slow log.
+	SlowThreshold time.Duration
+
+	// Values includes filter values in the records. By default they are replaced by "?",
+	// so only the shape of the filter is logged.
+	Values bool
+}
+
+// Logging returns a middleware logging every Model operation with its name, collection,
+// redacted filter, duration, result count and error.
+//
+// Example:
+//
+//	db.Use(mongo.Logging(slog.Default(), func(o *mongo.LogOptions) {
+//	    o.SlowThreshold = 100 * time.Millisecond
+//	}))
+func Logging(logger *slog.Logger, opts ...func(o *LogOptions)) Middleware {
+	opt := &LogOptions{}
+	for _, v := range opts {
+		v(opt)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next Handler) Handler {
+		return func(op *Operation) (any, error) {
+			start := time.Now()
+			res, err := next(op)
+			duration := time.Since(start)
+
+			attrs := []slog.Attr{
+				slog.String("op", op.Name),
+				slog.String("collection", op.Collection),
+				slog.String("filter", shape(op.Filter, opt.Values)),
+				slog.Duration("duration", duration),
+				slog.Int64("count", resultCount(res)),
+			}
+			if op.Pipeline != nil {
+				attrs = append(attrs, slog.String("pipeline", shape(op.Pipeline, opt.Values)))
+			}
+			logRecord(op.Ctx, logger, opt, "mongo operation", duration, op.Sort, err, attrs)
+			return res, err
+		}
+	}
+}
+
+// CommandMonitor returns a driver command monitor logging every command sent by the client,
+// including those issued through the embedded *mongo.Database. Set it with
+// ClientOptions.SetMonitor, or set ConnectOptions.Logger to install it with Logging.
+func CommandMonitor(logger *slog.Logger, opts ...func(o *LogOptions)) *event.CommandMonitor {
+	opt := &LogOptions{}
+	for _, v := range opts {
+		v(opt)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	type started struct {
+		collection string
+		filter     bson.Raw
+		sort       bson.Raw
+	}
+	var inflight sync.Map // request ID -> started
+
+	finish := func(ctx context.Context, e event.CommandFinishedEvent, reply bson.Raw, err error) {
+		v, _ := inflight.LoadAndDelete(e.RequestID)
+		s, _ := v.(started)
+
+		attrs := []slog.Attr{
+			slog.String("command", e.CommandName),
+			slog.String("database", e.DatabaseName),
+			slog.String("collection", s.collection),
+			slog.String("filter", shape(s.filter, opt.Values)),
+			slog.Duration("duration", e.Duration),
+		}
+		if reply != nil {
+			attrs = append(attrs, slog.Int64("count", replyCount(reply)))
+		}
+		var sort any
+		if s.sort != nil {
+			sort = s.sort
+		}
+		logRecord(ctx, logger, opt, "mongo command", e.Duration, sort, err, attrs)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			s := started{}
+			if v, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				s.collection = v
+			}
+			if v, ok := e.Command.Lookup("filter").DocumentOK(); ok {
+				s.filter = v
+			} else if v, ok := e.Command.Lookup("query").DocumentOK(); ok {
+				s.filter = v
+			}
+			if v, ok := e.Command.Lookup("sort").DocumentOK(); ok {
+				s.sort = v
+			}
+			inflight.Store(e.RequestID, s)
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(ctx, e.CommandFinishedEvent, e.Reply, nil)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(ctx, e.CommandFinishedEvent, nil, errors.New(e.Failure))
+		},
+	}
+}
+
+// logRecord writes an operation or command record, as a warning with the sort when it is slow.
+func logRecord(ctx context.Context, logger *slog.Logger, opt *LogOptions, msg string, duration time.Duration, sort any, err error, attrs []slog.Attr) {
+	level := opt.Level
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		if !errors.Is(err, ErrRecordNotFound) {
+			level = slog.LevelError
+		}
+	}
+	if opt.SlowThreshold > 0 && duration >= opt.SlowThreshold {
+		msg = "mongo slow " + strings.TrimPrefix(msg, "mongo ")
+		attrs = append(attrs, slog.String("sort", shape(sort, true)))
+		if level < slog.LevelWarn {
+			level = slog.LevelWarn
+		}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// chainMonitors returns a monitor calling both monitors.
+func chainMonitors(a, b *event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if a.Started != nil {
+				a.Started(ctx, e)
+			}
+			if b.Started != nil {
+				b.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			if a.Succeeded != nil {
+				a.Succeeded(ctx, e)
+			}
+			if b.Succeeded != nil {
+				b.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			if a.Failed != nil {
+				a.Failed(ctx, e)
+			}
+			if b.Failed != nil {
+				b.Failed(ctx, e)
+			}
+		},
+	}
+}
+
+// resultCount returns the number of documents of an operation result.
+func resultCount(res any) int64 {
+	switch v := res.(type) {
+	case M:
+		if v != nil {
+			return 1
+		}
+	case []M:
+		return int64(len(v))
+	case int64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+	case pageResult:
+		return int64(len(v.list))
+	case cursorPage:
+		return int64(len(v.list))
+	case *UpdateResult:
+		if v != nil {
+			return v.Modified + v.Upserted
+		}
+	case *BulkResult:
+		if v != nil {
+			return v.Inserted + v.Upserted + v.Modified + v.Deleted
+		}
+	}
+	return 0
+}
+
+// replyCount returns the number of documents of a command reply.
+func replyCount(reply bson.Raw) int64 {
+	if v, ok := reply.Lookup("cursor", "firstBatch").ArrayOK(); ok {
+		values, _ := v.Values()
+		return int64(len(values))
+	}
+	if v, ok := reply.Lookup("n").AsInt64OK(); ok {
+		return v
+	}
+	return 0
+}
+
+// shape renders a filter, sort or pipeline as compact JSON. Unless values is true,
+// every value is replaced by ?, e.g. {"age":{"$gte":?}}.
+func shape(v any, values bool) string {
+	if v == nil {
+		return "{}"
+	}
+	doc, err := toDocument(bson.D{{Key: "v", Value: v}})
+	if err != nil || len(doc) == 0 {
+		return fmt.Sprintf("%T", v)
+	}
+
+	var sb strings.Builder
+	writeShape(&sb, doc[0].Value, values)
+	return sb.String()
+}
+
+func writeShape(sb *strings.Builder, v any, values bool) {
+	switch t := v.(type) {
+	case bson.D:
+		sb.WriteByte('{')
+		for i, e := range t {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(strconv.Quote(e.Key))
+			sb.WriteByte(':')
+			writeShape(sb, e.Value, values)
+		}
+		sb.WriteByte('}')
+	case bson.A:
+		sb.WriteByte('[')
+		for i, e := range t {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			writeShape(sb, e, values)
+		}
+		sb.WriteByte(']')
+	default:
+		if !values {
+			sb.WriteByte('?')
+			return
+		}
+		if s, ok := v.(string); ok {
+			sb.WriteString(strconv.Quote(s))
+			return
+		}
+		fmt.Fprint(sb, v)
+	}
+}
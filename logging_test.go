@@ -0,0 +1,120 @@
+package mongo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMemoryLogging(t *testing.T) {
+	db := mongo.NewMemoryDatabase("test")
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	db.Use(mongo.Logging(logger, func(o *mongo.LogOptions) {
+		o.SlowThreshold = 20 * time.Millisecond
+	}))
+	// slow down counts
+	db.Use(func(next mongo.Handler) mongo.Handler {
+		return func(op *mongo.Operation) (any, error) {
+			if op.Name == "Count" {
+				time.Sleep(30 * time.Millisecond)
+			}
+			return next(op)
+		}
+	})
+
+	require.NoError(t, db.Set(&memUser{ID: "1", Name: "alice", Age: 30}))
+	require.NoError(t, db.Set(&memUser{ID: "2", Name: "bob", Age: 40}))
+
+	err := db.Txn(context.Background(), func(txn *mongo.Txn) error {
+		model := txn.Model(&memUser{})
+		list, err := model.Find(mongo.Where("name").Eq("alice").And(mongo.Where("age").Gte(18)), nil)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		_, err = model.Get("missing")
+		require.ErrorIs(t, err, mongo.ErrRecordNotFound)
+		_, err = model.Count(mongo.Where("age").Gt(35))
+		return err
+	})
+	require.NoError(t, err)
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 5)
+
+	find := records[2]
+	require.Equal(t, "INFO", find["level"])
+	require.Equal(t, "mongo operation", find["msg"])
+	require.Equal(t, "Find", find["op"])
+	require.Equal(t, "mem_user", find["collection"])
+	require.Equal(t, `{"name":?,"age":{"$gte":?}}`, find["filter"])
+	require.EqualValues(t, 1, find["count"])
+	require.NotContains(t, buf.String(), "alice")
+
+	get := records[3]
+	require.Equal(t, "INFO", get["level"])
+	require.Equal(t, mongo.ErrRecordNotFound.Error(), get["error"])
+
+	count := records[4]
+	require.Equal(t, "WARN", count["level"])
+	require.Equal(t, "mongo slow operation", count["msg"])
+	require.Equal(t, `{"age":{"$gt":?}}`, count["filter"])
+	require.Equal(t, "{}", count["sort"])
+	require.EqualValues(t, 1, count["count"])
+}
+
+func TestCommandMonitorLogging(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	monitor := mongo.CommandMonitor(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	command, err := bson.Marshal(bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.D{{Key: "email", Value: "a@b.c"}}},
+		{Key: "sort", Value: bson.D{{Key: "age", Value: -1}}},
+	})
+	require.NoError(t, err)
+	reply, err := bson.Marshal(bson.D{{Key: "cursor", Value: bson.D{
+		{Key: "firstBatch", Value: bson.A{bson.D{}, bson.D{}}},
+	}}})
+	require.NoError(t, err)
+
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: command, DatabaseName: "app", CommandName: "find", RequestID: 7})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", DatabaseName: "app", RequestID: 7, Duration: time.Millisecond},
+		Reply:                reply,
+	})
+	monitor.Failed(ctx, &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", DatabaseName: "app", RequestID: 8},
+		Failure:              "boom",
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, "mongo command", record["msg"])
+	require.Equal(t, "find", record["command"])
+	require.Equal(t, "users", record["collection"])
+	require.Equal(t, `{"email":?}`, record["filter"])
+	require.EqualValues(t, 2, record["count"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	require.Equal(t, "ERROR", record["level"])
+	require.Equal(t, "boom", record["error"])
+}
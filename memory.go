@@ -0,0 +1,907 @@
+// Package mongo provides an in-memory storage backend for tests without a mongod.
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryBackend is an in-memory Backend intended for unit tests.
+// It supports _id lookups, the common query and update operators, sort, skip/limit,
+// projection and unique indexes created with Database.Indexes. Multi-document
+// transactions are serialized and rolled back on error.
+type MemoryBackend struct {
+	name string
+
+	mu          sync.Mutex
+	collections map[string]*memoryCollection
+
+	// txnMu serializes multi-document transactions
+	txnMu sync.Mutex
+}
+
+// NewMemoryBackend creates an empty in-memory backend for the named database.
+func NewMemoryBackend(name string) *MemoryBackend {
+	return &MemoryBackend{name: name, collections: make(map[string]*memoryCollection)}
+}
+
+// NewMemoryDatabase creates a database backed by memory instead of a MongoDB server.
+// It can replace NewDatabase in tests; the embedded driver client and database are nil.
+//
+// Example:
+//
+//	db := mongo.NewMemoryDatabase("test")
+//	err := db.Indexes(ctx, &User{})
+//	err = db.Set(&User{ID: "user123", Name: "John"})
+func NewMemoryDatabase(name string) *Database {
+	return NewDatabaseWithBackend(NewMemoryBackend(name))
+}
+
+// Collection returns the in-memory store for the named collection, creating it if needed.
+func (b *MemoryBackend) Collection(name string) Store {
+	return b.collection(name)
+}
+
+func (b *MemoryBackend) collection(name string) *memoryCollection {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.collections[name]
+	if !ok {
+		c = &memoryCollection{db: b.name, name: name}
+		b.collections[name] = c
+	}
+	return c
+}
+
+// WithTransaction runs fn exclusively of other transactions and restores all
+// collections to their previous contents if fn returns an error.
+func (b *MemoryBackend) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	b.txnMu.Lock()
+	defer b.txnMu.Unlock()
+
+	b.mu.Lock()
+	snapshot := make(map[string][]bson.D, len(b.collections))
+	for name, c := range b.collections {
+		c.mu.RLock()
+		snapshot[name] = append([]bson.D(nil), c.docs...)
+		c.mu.RUnlock()
+	}
+	b.mu.Unlock()
+
+	err := fn(ctx)
+	if err != nil {
+		b.mu.Lock()
+		for name, c := range b.collections {
+			c.mu.Lock()
+			c.docs = snapshot[name]
+			c.mu.Unlock()
+		}
+		b.mu.Unlock()
+	}
+	return err
+}
+
+// Watch is not supported by the memory backend.
+func (b *MemoryBackend) Watch(context.Context, any, ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+	return nil, ErrNotSupported
+}
+
+// Indexes returns the index manager of the named collection. Unique indexes are enforced on writes.
+func (b *MemoryBackend) Indexes(collection string) IndexManager {
+	return memoryIndexes{c: b.collection(collection)}
+}
+
+// memoryIndexes manages the index list of a memory collection.
+type memoryIndexes struct {
+	c *memoryCollection
+}
+
+func (x memoryIndexes) List(context.Context) ([]IndexSpec, error) {
+	x.c.mu.RLock()
+	defer x.c.mu.RUnlock()
+
+	specs := []IndexSpec{idIndex.spec}
+	for _, index := range x.c.indexes {
+		specs = append(specs, index.spec)
+	}
+	return specs, nil
+}
+
+func (x memoryIndexes) Create(_ context.Context, spec IndexSpec) error {
+	x.c.mu.Lock()
+	defer x.c.mu.Unlock()
+
+	for _, index := range x.c.indexes {
+		if index.spec.Name == spec.Name {
+			return fmt.Errorf("index already exists with a different name or options: %s", spec.Name)
+		}
+	}
+
+	if len(spec.Partial) > 0 {
+		partial, err := toDocument(spec.Partial)
+		if err != nil {
+			return err
+		}
+		spec.Partial = partial
+	}
+
+	index := memoryIndex{spec: spec}
+	if spec.Unique {
+		for i, doc := range x.c.docs {
+			if err := x.c.checkIndex(index, doc, i); err != nil {
+				return err
+			}
+		}
+	}
+	x.c.indexes = append(x.c.indexes, index)
+	return nil
+}
+
+func (x memoryIndexes) Drop(_ context.Context, name string) error {
+	x.c.mu.Lock()
+	defer x.c.mu.Unlock()
+
+	for i, index := range x.c.indexes {
+		if index.spec.Name == name {
+			x.c.indexes = append(x.c.indexes[:i:i], x.c.indexes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("index not found with name [%s]", name)
+}
+
+type memoryIndex struct {
+	spec IndexSpec
+}
+
+// fields returns the indexed field paths in key order.
+func (x memoryIndex) fields() []string {
+	fields := make([]string, 0, len(x.spec.Keys))
+	for _, k := range x.spec.Keys {
+		fields = append(fields, k.Key)
+	}
+	return fields
+}
+
+// covers reports whether doc is part of a sparse or partial index.
+func (x memoryIndex) covers(doc bson.D) bool {
+	if x.spec.Sparse {
+		found := false
+		for _, field := range x.fields() {
+			if _, ok := getPath(doc, field); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(x.spec.Partial) > 0 {
+		ok, err := matchDocument(doc, x.spec.Partial)
+		return err == nil && ok
+	}
+	return true
+}
+
+// idIndex is the implicit unique index on _id.
+var idIndex = memoryIndex{spec: IndexSpec{Name: "_id_", Keys: bson.D{{Key: "_id", Value: 1}}, Unique: true}}
+
+// memoryCollection stores documents in insertion order. Stored documents are never
+// mutated in place, so snapshots only need to copy the slice.
+type memoryCollection struct {
+	db, name string
+
+	mu      sync.RWMutex
+	docs    []bson.D
+	indexes []memoryIndex
+}
+
+func (c *memoryCollection) Name() string {
+	return c.name
+}
+
+func (c *memoryCollection) FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	opt := options.FindOne()
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			opt.Sort = o.Sort
+		}
+		if o.Skip != nil {
+			opt.Skip = o.Skip
+		}
+		if o.Projection != nil {
+			opt.Projection = o.Projection
+		}
+	}
+
+	var skip int64
+	if opt.Skip != nil {
+		skip = *opt.Skip
+	}
+	docs, err := c.find(ctx, filter, opt.Sort, opt.Projection, skip, 1)
+	return singleResult(docs, err)
+}
+
+func (c *memoryCollection) Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	opt := options.Find()
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			opt.Sort = o.Sort
+		}
+		if o.Skip != nil {
+			opt.Skip = o.Skip
+		}
+		if o.Limit != nil {
+			opt.Limit = o.Limit
+		}
+		if o.Projection != nil {
+			opt.Projection = o.Projection
+		}
+	}
+
+	var skip, limit int64
+	if opt.Skip != nil {
+		skip = *opt.Skip
+	}
+	if opt.Limit != nil {
+		limit = *opt.Limit
+		if limit < 0 {
+			limit = -limit
+		}
+	}
+
+	docs, err := c.find(ctx, filter, opt.Sort, opt.Projection, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return newMemoryCursor(docs)
+}
+
+func (c *memoryCollection) CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+	var skip, limit int64
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Skip != nil {
+			skip = *o.Skip
+		}
+		if o.Limit != nil {
+			limit = *o.Limit
+		}
+	}
+
+	docs, err := c.find(ctx, filter, nil, nil, skip, limit)
+	return int64(len(docs)), err
+}
+
+func (c *memoryCollection) EstimatedDocumentCount(ctx context.Context, _ ...*options.EstimatedDocumentCountOptions) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return int64(len(c.docs)), nil
+}
+
+func (c *memoryCollection) ReplaceOne(ctx context.Context, filter, replacement any, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	upsert := false
+	for _, o := range opts {
+		if o != nil && o.Upsert != nil {
+			upsert = *o.Upsert
+		}
+	}
+
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := toDocument(replacement)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range doc {
+		if isOperator(e.Key) {
+			return nil, fmt.Errorf("replacement document cannot contain keys beginning with '$': %s", e.Key)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, err := c.firstLocked(f)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		if !upsert {
+			return &mongo.UpdateResult{}, nil
+		}
+		if _, ok := docGet(doc, "_id"); !ok {
+			if id, ok := docGet(upsertDocument(f), "_id"); ok {
+				doc = docSet(doc, "_id", id)
+			}
+		}
+		doc, err = c.insertLocked(doc)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := docGet(doc, "_id")
+		return &mongo.UpdateResult{UpsertedCount: 1, UpsertedID: id}, nil
+	}
+
+	old := c.docs[i]
+	id, _ := docGet(old, "_id")
+	if newID, ok := docGet(doc, "_id"); ok && !valuesEqual(newID, id) {
+		return nil, immutableIDError()
+	}
+	doc = withID(doc, id)
+	if err := c.replaceLocked(i, doc); err != nil {
+		return nil, err
+	}
+
+	res := &mongo.UpdateResult{MatchedCount: 1}
+	if !valuesEqual(old, doc) {
+		res.ModifiedCount = 1
+	}
+	return res, nil
+}
+
+func (c *memoryCollection) UpdateOne(ctx context.Context, filter, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.update(ctx, filter, update, false, opts)
+}
+
+func (c *memoryCollection) UpdateByID(ctx context.Context, id, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.update(ctx, GetIDFilter(id), update, false, opts)
+}
+
+func (c *memoryCollection) UpdateMany(ctx context.Context, filter, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.update(ctx, filter, update, true, opts)
+}
+
+func (c *memoryCollection) update(ctx context.Context, filter, update any, many bool, opts []*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	upsert := false
+	for _, o := range opts {
+		if o != nil && o.Upsert != nil {
+			upsert = *o.Upsert
+		}
+	}
+
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	u, err := toUpdateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := &mongo.UpdateResult{}
+	for i, doc := range c.docs {
+		ok, err := matchDocument(doc, f)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		res.MatchedCount++
+		newDoc, err := applyUpdate(doc, u, false)
+		if err != nil {
+			return nil, err
+		}
+		if !valuesEqual(doc, newDoc) {
+			if err := c.replaceLocked(i, newDoc); err != nil {
+				return nil, err
+			}
+			res.ModifiedCount++
+		}
+		if !many {
+			break
+		}
+	}
+
+	if res.MatchedCount == 0 && upsert {
+		doc, err := applyUpdate(upsertDocument(f), u, true)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = c.insertLocked(doc)
+		if err != nil {
+			return nil, err
+		}
+		res.UpsertedCount = 1
+		res.UpsertedID, _ = docGet(doc, "_id")
+	}
+	return res, nil
+}
+
+func (c *memoryCollection) FindOneAndUpdate(ctx context.Context, filter, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	if err := ctx.Err(); err != nil {
+		return singleResult(nil, err)
+	}
+	opt := options.FindOneAndUpdate()
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			opt.Sort = o.Sort
+		}
+		if o.Projection != nil {
+			opt.Projection = o.Projection
+		}
+		if o.Upsert != nil {
+			opt.Upsert = o.Upsert
+		}
+		if o.ReturnDocument != nil {
+			opt.ReturnDocument = o.ReturnDocument
+		}
+	}
+	returnAfter := opt.ReturnDocument != nil && *opt.ReturnDocument == options.After
+
+	f, err := toDocument(filter)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	u, err := toUpdateDocument(update)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	projection, err := toDocument(opt.Projection)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	indexes, err := c.matchLocked(f, opt.Sort)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+
+	if len(indexes) == 0 {
+		if opt.Upsert == nil || !*opt.Upsert {
+			return singleResult(nil, nil)
+		}
+		doc, err := applyUpdate(upsertDocument(f), u, true)
+		if err != nil {
+			return singleResult(nil, err)
+		}
+		doc, err = c.insertLocked(doc)
+		if err != nil || !returnAfter {
+			return singleResult(nil, err)
+		}
+		return singleResult([]bson.D{projectDocument(doc, projection)}, nil)
+	}
+
+	i := indexes[0]
+	old := c.docs[i]
+	doc, err := applyUpdate(old, u, false)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	if err := c.replaceLocked(i, doc); err != nil {
+		return singleResult(nil, err)
+	}
+
+	if returnAfter {
+		return singleResult([]bson.D{projectDocument(doc, projection)}, nil)
+	}
+	return singleResult([]bson.D{projectDocument(old, projection)}, nil)
+}
+
+func (c *memoryCollection) DeleteOne(ctx context.Context, filter any, _ ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, err := c.firstLocked(f)
+	if err != nil || i < 0 {
+		return &mongo.DeleteResult{}, err
+	}
+	c.docs = append(c.docs[:i:i], c.docs[i+1:]...)
+	return &mongo.DeleteResult{DeletedCount: 1}, nil
+}
+
+// BulkWrite applies insert, replace, update and delete models one by one.
+// Failed models are reported in a mongo.BulkWriteException; ordered writes stop at the first failure.
+func (c *memoryCollection) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	if len(models) == 0 {
+		return nil, mongo.ErrEmptySlice
+	}
+	ordered := true
+	for _, o := range opts {
+		if o != nil && o.Ordered != nil {
+			ordered = *o.Ordered
+		}
+	}
+
+	res := &mongo.BulkWriteResult{UpsertedIDs: make(map[int64]any)}
+	var exception mongo.BulkWriteException
+	for i, model := range models {
+		var r *mongo.UpdateResult
+		var err error
+		switch wm := model.(type) {
+		case *mongo.InsertOneModel:
+			err = c.insertOne(ctx, wm.Document)
+			if err == nil {
+				res.InsertedCount++
+			}
+		case *mongo.ReplaceOneModel:
+			r, err = c.ReplaceOne(ctx, wm.Filter, wm.Replacement, &options.ReplaceOptions{Upsert: wm.Upsert})
+		case *mongo.UpdateOneModel:
+			r, err = c.UpdateOne(ctx, wm.Filter, wm.Update, &options.UpdateOptions{Upsert: wm.Upsert})
+		case *mongo.UpdateManyModel:
+			r, err = c.UpdateMany(ctx, wm.Filter, wm.Update, &options.UpdateOptions{Upsert: wm.Upsert})
+		case *mongo.DeleteOneModel:
+			var d *mongo.DeleteResult
+			d, err = c.DeleteOne(ctx, wm.Filter)
+			if err == nil {
+				res.DeletedCount += d.DeletedCount
+			}
+		case *mongo.DeleteManyModel:
+			var n int64
+			n, err = c.deleteMany(ctx, wm.Filter)
+			res.DeletedCount += n
+		default:
+			err = fmt.Errorf("memory backend: unsupported write model %T", model)
+		}
+
+		if r != nil {
+			res.MatchedCount += r.MatchedCount
+			res.ModifiedCount += r.ModifiedCount
+			res.UpsertedCount += r.UpsertedCount
+			if r.UpsertedID != nil {
+				res.UpsertedIDs[int64(i)] = r.UpsertedID
+			}
+		}
+		if err != nil {
+			we := mongo.WriteError{Index: i, Message: err.Error()}
+			var wex mongo.WriteException
+			if errors.As(err, &wex) && len(wex.WriteErrors) > 0 {
+				we.Code = wex.WriteErrors[0].Code
+				we.Message = wex.WriteErrors[0].Message
+			}
+			exception.WriteErrors = append(exception.WriteErrors, mongo.BulkWriteError{WriteError: we, Request: model})
+			if ordered {
+				break
+			}
+		}
+	}
+
+	if len(exception.WriteErrors) > 0 {
+		return res, exception
+	}
+	return res, nil
+}
+
+func (c *memoryCollection) insertOne(ctx context.Context, document any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	doc, err := toDocument(document)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.insertLocked(doc)
+	return err
+}
+
+func (c *memoryCollection) deleteMany(ctx context.Context, filter any) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	f, err := toDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := make([]bson.D, 0, len(c.docs))
+	for _, doc := range c.docs {
+		ok, err := matchDocument(doc, f)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			kept = append(kept, doc)
+		}
+	}
+	deleted := int64(len(c.docs) - len(kept))
+	c.docs = kept
+	return deleted, nil
+}
+
+// Aggregate supports the $match, $sort, $skip, $limit, $project, $sample and $count stages.
+func (c *memoryCollection) Aggregate(ctx context.Context, pipeline any, _ ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	raw, err := bson.Marshal(bson.D{{Key: "pipeline", Value: pipeline}})
+	if err != nil {
+		return nil, err
+	}
+	var p struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}
+	if err := bson.Unmarshal(raw, &p); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	docs := append([]bson.D(nil), c.docs...)
+	c.mu.RUnlock()
+
+	for _, stage := range p.Pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("a pipeline stage specification object must contain exactly one field")
+		}
+
+		switch spec := stage[0].Value; stage[0].Key {
+		case "$match":
+			filter, _ := spec.(bson.D)
+			matched := docs[:0:0]
+			for _, doc := range docs {
+				ok, err := matchDocument(doc, filter)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					matched = append(matched, doc)
+				}
+			}
+			docs = matched
+		case "$sort":
+			s, _ := spec.(bson.D)
+			sort.SliceStable(docs, func(i, j int) bool {
+				return compareBySort(docs[i], docs[j], s) < 0
+			})
+		case "$skip":
+			n := int(toFloat(spec))
+			if n > len(docs) {
+				n = len(docs)
+			}
+			docs = docs[n:]
+		case "$limit":
+			if n := int(toFloat(spec)); n < len(docs) {
+				docs = docs[:n]
+			}
+		case "$project":
+			projection, _ := spec.(bson.D)
+			projected := make([]bson.D, 0, len(docs))
+			for _, doc := range docs {
+				projected = append(projected, projectDocument(doc, projection))
+			}
+			docs = projected
+		case "$sample":
+			s, _ := spec.(bson.D)
+			size, _ := docGet(s, "size")
+			rand.Shuffle(len(docs), func(i, j int) { docs[i], docs[j] = docs[j], docs[i] })
+			if n := int(toFloat(size)); n < len(docs) {
+				docs = docs[:n]
+			}
+		case "$count":
+			field, _ := spec.(string)
+			docs = []bson.D{{{Key: field, Value: int32(len(docs))}}}
+		default:
+			return nil, fmt.Errorf("memory backend: unsupported pipeline stage %s", stage[0].Key)
+		}
+	}
+	return newMemoryCursor(docs)
+}
+
+// Watch is not supported by the memory backend.
+func (c *memoryCollection) Watch(context.Context, any, ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+	return nil, ErrNotSupported
+}
+
+// find returns the projected documents matching filter, sorted, skipped and limited.
+func (c *memoryCollection) find(ctx context.Context, filter, sortSpec, projectionSpec any, skip, limit int64) ([]bson.D, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := toDocument(projectionSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	indexes, err := c.matchLocked(f, sortSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	if skip > 0 {
+		if skip > int64(len(indexes)) {
+			skip = int64(len(indexes))
+		}
+		indexes = indexes[skip:]
+	}
+	if limit > 0 && limit < int64(len(indexes)) {
+		indexes = indexes[:limit]
+	}
+
+	docs := make([]bson.D, 0, len(indexes))
+	for _, i := range indexes {
+		docs = append(docs, projectDocument(c.docs[i], projection))
+	}
+	return docs, nil
+}
+
+// matchLocked returns the positions of the documents matching filter in sort order.
+func (c *memoryCollection) matchLocked(filter bson.D, sortSpec any) ([]int, error) {
+	var indexes []int
+	for i, doc := range c.docs {
+		ok, err := matchDocument(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+
+	s, err := toDocument(sortSpec)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) > 0 {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return compareBySort(c.docs[indexes[i]], c.docs[indexes[j]], s) < 0
+		})
+	}
+	return indexes, nil
+}
+
+// firstLocked returns the position of the first document matching filter, or -1.
+func (c *memoryCollection) firstLocked(filter bson.D) (int, error) {
+	for i, doc := range c.docs {
+		ok, err := matchDocument(doc, filter)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func (c *memoryCollection) insertLocked(doc bson.D) (bson.D, error) {
+	id, ok := docGet(doc, "_id")
+	if !ok {
+		id = primitive.NewObjectID()
+	}
+	doc = withID(doc, id)
+	if err := c.checkUniqueLocked(doc, -1); err != nil {
+		return nil, err
+	}
+	c.docs = append(c.docs, doc)
+	return doc, nil
+}
+
+func (c *memoryCollection) replaceLocked(i int, doc bson.D) error {
+	if err := c.checkUniqueLocked(doc, i); err != nil {
+		return err
+	}
+	c.docs[i] = doc
+	return nil
+}
+
+// checkUniqueLocked verifies that doc violates neither _id nor a unique index,
+// ignoring the document stored at position skip.
+func (c *memoryCollection) checkUniqueLocked(doc bson.D, skip int) error {
+	if err := c.checkIndex(idIndex, doc, skip); err != nil {
+		return err
+	}
+	for _, index := range c.indexes {
+		if !index.spec.Unique {
+			continue
+		}
+		if err := c.checkIndex(index, doc, skip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *memoryCollection) checkIndex(index memoryIndex, doc bson.D, skip int) error {
+	if !index.covers(doc) {
+		return nil
+	}
+	fields := index.fields()
+	key := indexKey(doc, fields)
+	for j, other := range c.docs {
+		if j != skip && index.covers(other) && valuesEqual(key, indexKey(other, fields)) {
+			return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+				Code:    11000,
+				Message: fmt.Sprintf("E11000 duplicate key error collection: %s.%s index: %s dup key: %v", c.db, c.name, index.spec.Name, key),
+			}}}
+		}
+	}
+	return nil
+}
+
+// indexKey returns the values of fields in doc, using null for missing fields.
+func indexKey(doc bson.D, fields []string) bson.A {
+	key := make(bson.A, 0, len(fields))
+	for _, field := range fields {
+		v, _ := getPath(doc, field)
+		key = append(key, v)
+	}
+	return key
+}
+
+func immutableIDError() error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    66,
+		Message: "Performing an update on the path '_id' would modify the immutable field '_id'",
+	}}}
+}
+
+// singleResult builds a driver result from the first document, mapping no documents to ErrNoDocuments.
+func singleResult(docs []bson.D, err error) *mongo.SingleResult {
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+	}
+	if len(docs) == 0 {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(docs[0], nil, nil)
+}
+
+func newMemoryCursor(docs []bson.D) (*mongo.Cursor, error) {
+	items := make([]any, 0, len(docs))
+	for _, doc := range docs {
+		items = append(items, doc)
+	}
+	return mongo.NewCursorFromDocuments(items, nil, nil)
+}
@@ -0,0 +1,931 @@
+// Package mongo implements query matching, sorting, projection and updates for the memory backend.
+package mongo
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// toDocument normalizes any document-like value (M, bson.D, Query, struct, ...) into a bson.D
+// by round-tripping it through BSON, so the memory backend only deals with BSON value types.
+func toDocument(v any) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.D{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// toUpdateDocument normalizes an update document and checks that it only contains operators.
+func toUpdateDocument(v any) (bson.D, error) {
+	if _, ok := v.(bson.A); ok {
+		return nil, fmt.Errorf("memory backend: update pipelines are not supported")
+	}
+	doc, err := toDocument(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return nil, fmt.Errorf("update document must not be empty")
+	}
+	for _, e := range doc {
+		if !isOperator(e.Key) {
+			return nil, fmt.Errorf("update document must contain key beginning with '$': %s", e.Key)
+		}
+	}
+	return doc, nil
+}
+
+func isOperator(key string) bool {
+	return strings.HasPrefix(key, "$")
+}
+
+// operatorDocument reports whether v is a document of query operators such as {$gt: 1}.
+func operatorDocument(v any) (bson.D, bool) {
+	d, ok := v.(bson.D)
+	if !ok || len(d) == 0 || !isOperator(d[0].Key) {
+		return nil, false
+	}
+	return d, true
+}
+
+func docGet(doc bson.D, key string) (any, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// docSet replaces the value of key in place or appends it.
+func docSet(doc bson.D, key string, v any) bson.D {
+	for i, e := range doc {
+		if e.Key == key {
+			doc[i].Value = v
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: v})
+}
+
+func docDel(doc bson.D, key string) bson.D {
+	for i, e := range doc {
+		if e.Key == key {
+			return append(doc[:i:i], doc[i+1:]...)
+		}
+	}
+	return doc
+}
+
+// withID returns doc with _id set to id as its first field.
+func withID(doc bson.D, id any) bson.D {
+	out := bson.D{{Key: "_id", Value: id}}
+	for _, e := range doc {
+		if e.Key != "_id" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// copyValue deep-copies documents and arrays so updates never mutate stored documents.
+func copyValue(v any) any {
+	switch t := v.(type) {
+	case bson.D:
+		out := make(bson.D, len(t))
+		for i, e := range t {
+			out[i] = bson.E{Key: e.Key, Value: copyValue(e.Value)}
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(t))
+		for i, e := range t {
+			out[i] = copyValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+// lookup resolves a dotted path, traversing arrays of documents, and returns every value found.
+func lookup(v any, parts []string) (values []any, exists bool) {
+	if len(parts) == 0 {
+		return []any{v}, true
+	}
+
+	switch t := v.(type) {
+	case bson.D:
+		child, ok := docGet(t, parts[0])
+		if !ok {
+			return nil, false
+		}
+		return lookup(child, parts[1:])
+	case bson.A:
+		if idx, err := strconv.Atoi(parts[0]); err == nil {
+			if idx < 0 || idx >= len(t) {
+				return nil, false
+			}
+			return lookup(t[idx], parts[1:])
+		}
+		for _, e := range t {
+			if _, ok := e.(bson.D); !ok {
+				continue
+			}
+			vals, ok := lookup(e, parts)
+			if ok {
+				exists = true
+				values = append(values, vals...)
+			}
+		}
+		return values, exists
+	}
+	return nil, false
+}
+
+// getPath resolves a dotted path without traversing arrays of documents.
+func getPath(v any, path string) (any, bool) {
+	for _, part := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case bson.D:
+			child, ok := docGet(t, part)
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case bson.A:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return nil, false
+			}
+			v = t[idx]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// setPath sets a dotted path, creating intermediate documents as needed.
+func setPath(doc bson.D, path string, v any) (bson.D, error) {
+	out, err := setIn(doc, strings.Split(path, "."), v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot set %s: %w", path, err)
+	}
+	return out.(bson.D), nil
+}
+
+func setIn(container any, parts []string, v any) (any, error) {
+	if len(parts) == 0 {
+		return v, nil
+	}
+	if container == nil {
+		container = bson.D{}
+	}
+
+	switch t := container.(type) {
+	case bson.D:
+		child, _ := docGet(t, parts[0])
+		nv, err := setIn(child, parts[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		return docSet(t, parts[0], nv), nil
+	case bson.A:
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 {
+			return nil, fmt.Errorf("invalid array index %q", parts[0])
+		}
+		for len(t) <= idx {
+			t = append(t, nil)
+		}
+		nv, err := setIn(t[idx], parts[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		t[idx] = nv
+		return t, nil
+	}
+	return nil, fmt.Errorf("field %q is not a document", parts[0])
+}
+
+// unsetPath removes a dotted path if present.
+func unsetPath(doc bson.D, path string) bson.D {
+	out, _ := unsetIn(doc, strings.Split(path, "."))
+	return out.(bson.D)
+}
+
+func unsetIn(container any, parts []string) (any, bool) {
+	switch t := container.(type) {
+	case bson.D:
+		if len(parts) == 1 {
+			return docDel(t, parts[0]), true
+		}
+		child, ok := docGet(t, parts[0])
+		if !ok {
+			return t, false
+		}
+		nv, ok := unsetIn(child, parts[1:])
+		if ok {
+			t = docSet(t, parts[0], nv)
+		}
+		return t, ok
+	case bson.A:
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 || idx >= len(t) {
+			return t, false
+		}
+		if len(parts) == 1 {
+			// unsetting an array element leaves null in its place
+			t[idx] = nil
+			return t, true
+		}
+		nv, ok := unsetIn(t[idx], parts[1:])
+		if ok {
+			t[idx] = nv
+		}
+		return t, ok
+	}
+	return container, false
+}
+
+// matchDocument reports whether doc satisfies the filter.
+func matchDocument(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchElement(doc, e)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchElement(doc bson.D, e bson.E) (bool, error) {
+	switch e.Key {
+	case "$and", "$or", "$nor":
+		arr, ok := e.Value.(bson.A)
+		if !ok || len(arr) == 0 {
+			return false, fmt.Errorf("%s argument must be a non-empty array", e.Key)
+		}
+		for _, v := range arr {
+			sub, ok := v.(bson.D)
+			if !ok {
+				return false, fmt.Errorf("%s elements must be documents", e.Key)
+			}
+			matched, err := matchDocument(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case e.Key == "$and" && !matched:
+				return false, nil
+			case e.Key == "$or" && matched:
+				return true, nil
+			case e.Key == "$nor" && matched:
+				return false, nil
+			}
+		}
+		return e.Key != "$or", nil
+	case "$comment":
+		return true, nil
+	}
+	if isOperator(e.Key) {
+		return false, fmt.Errorf("memory backend: unsupported query operator %s", e.Key)
+	}
+
+	values, exists := lookup(doc, strings.Split(e.Key, "."))
+	if ops, ok := operatorDocument(e.Value); ok {
+		return matchOperators(values, exists, ops)
+	}
+	return matchEq(values, exists, e.Value), nil
+}
+
+// expand adds the elements of array values, which query operators match individually.
+func expand(values []any) []any {
+	out := make([]any, 0, len(values))
+	for _, v := range values {
+		out = append(out, v)
+		if arr, ok := v.(bson.A); ok {
+			out = append(out, arr...)
+		}
+	}
+	return out
+}
+
+func matchEq(values []any, exists bool, target any) bool {
+	if re, ok := target.(primitive.Regex); ok {
+		return matchRegex(values, re)
+	}
+	if target == nil && !exists {
+		return true
+	}
+	for _, v := range expand(values) {
+		if valuesEqual(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchIn(values []any, exists bool, target any) (bool, error) {
+	arr, ok := target.(bson.A)
+	if !ok {
+		return false, fmt.Errorf("$in/$nin needs an array")
+	}
+	for _, v := range arr {
+		if matchEq(values, exists, v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchCompare(values []any, op string, target any) bool {
+	for _, v := range expand(values) {
+		if typeOrder(v) != typeOrder(target) {
+			continue
+		}
+		c := compareValues(v, target)
+		switch {
+		case op == "$gt" && c > 0,
+			op == "$gte" && c >= 0,
+			op == "$lt" && c < 0,
+			op == "$lte" && c <= 0:
+			return true
+		}
+	}
+	return false
+}
+
+func matchRegex(values []any, re primitive.Regex) bool {
+	flags := ""
+	for _, o := range re.Options {
+		if strings.ContainsRune("ims", o) {
+			flags += string(o)
+		}
+	}
+	pattern := re.Pattern
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return false
+	}
+	for _, v := range expand(values) {
+		if s, ok := v.(string); ok && compiled.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperators(values []any, exists bool, ops bson.D) (bool, error) {
+	for _, op := range ops {
+		var ok bool
+		var err error
+		switch op.Key {
+		case "$eq":
+			ok = matchEq(values, exists, op.Value)
+		case "$ne":
+			ok = !matchEq(values, exists, op.Value)
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = matchCompare(values, op.Key, op.Value)
+		case "$in":
+			ok, err = matchIn(values, exists, op.Value)
+		case "$nin":
+			ok, err = matchIn(values, exists, op.Value)
+			ok = !ok
+		case "$exists":
+			ok = exists == truthy(op.Value)
+		case "$regex":
+			re := primitive.Regex{}
+			switch p := op.Value.(type) {
+			case string:
+				re.Pattern = p
+			case primitive.Regex:
+				re = p
+			default:
+				return false, fmt.Errorf("$regex has to be a string")
+			}
+			if options, found := docGet(ops, "$options"); found {
+				re.Options, _ = options.(string)
+			}
+			ok = matchRegex(values, re)
+		case "$options":
+			continue
+		case "$not":
+			switch inner := op.Value.(type) {
+			case primitive.Regex:
+				ok = !matchRegex(values, inner)
+			case bson.D:
+				ok, err = matchOperators(values, exists, inner)
+				ok = !ok
+			default:
+				return false, fmt.Errorf("$not needs a regex or a document")
+			}
+		case "$elemMatch":
+			ok, err = matchElemMatch(values, op.Value)
+		case "$size":
+			n := toFloat(op.Value)
+			for _, v := range values {
+				if arr, isArr := v.(bson.A); isArr && float64(len(arr)) == n {
+					ok = true
+				}
+			}
+		case "$all":
+			arr, isArr := op.Value.(bson.A)
+			if !isArr {
+				return false, fmt.Errorf("$all needs an array")
+			}
+			ok = len(arr) > 0
+			for _, v := range arr {
+				if !matchEq(values, exists, v) {
+					ok = false
+					break
+				}
+			}
+		default:
+			return false, fmt.Errorf("memory backend: unsupported query operator %s", op.Key)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchElemMatch(values []any, cond any) (bool, error) {
+	q, ok := cond.(bson.D)
+	if !ok {
+		return false, fmt.Errorf("$elemMatch needs an object")
+	}
+	ops, isOps := operatorDocument(q)
+	for _, v := range values {
+		arr, ok := v.(bson.A)
+		if !ok {
+			continue
+		}
+		for _, e := range arr {
+			var matched bool
+			var err error
+			if isOps && ops[0].Key != "$and" && ops[0].Key != "$or" && ops[0].Key != "$nor" {
+				matched, err = matchOperators([]any{e}, true, ops)
+			} else if doc, ok := e.(bson.D); ok {
+				matched, err = matchDocument(doc, q)
+			}
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case nil:
+		return false
+	case int32, int64, float64:
+		return toFloat(t) != 0
+	}
+	return true
+}
+
+func toFloat(v any) float64 {
+	switch t := v.(type) {
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case float64:
+		return t
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(t.String(), 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	}
+	return math.NaN()
+}
+
+// typeOrder returns the BSON comparison order of a value's type.
+func typeOrder(v any) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 1
+	case nil, primitive.Null, primitive.Undefined:
+		return 2
+	case int32, int64, float64, primitive.Decimal128:
+		return 3
+	case string, primitive.Symbol:
+		return 4
+	case bson.D:
+		return 5
+	case bson.A:
+		return 6
+	case primitive.Binary:
+		return 7
+	case primitive.ObjectID:
+		return 8
+	case bool:
+		return 9
+	case primitive.DateTime:
+		return 10
+	case primitive.Timestamp:
+		return 11
+	case primitive.Regex:
+		return 12
+	case primitive.MaxKey:
+		return 14
+	}
+	return 13
+}
+
+func valuesEqual(a, b any) bool {
+	return typeOrder(a) == typeOrder(b) && compareValues(a, b) == 0
+}
+
+// compareValues orders two BSON values following the MongoDB comparison order.
+func compareValues(a, b any) int {
+	if oa, ob := typeOrder(a), typeOrder(b); oa != ob {
+		return cmpInt(int64(oa), int64(ob))
+	}
+
+	switch x := a.(type) {
+	case int32, int64, float64, primitive.Decimal128:
+		if ia, ok := toInt64(a); ok {
+			if ib, ok := toInt64(b); ok {
+				return cmpInt(ia, ib)
+			}
+		}
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, fmt.Sprint(b))
+	case primitive.Symbol:
+		return strings.Compare(string(x), fmt.Sprint(b))
+	case bson.D:
+		y := b.(bson.D)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := strings.Compare(x[i].Key, y[i].Key); c != 0 {
+				return c
+			}
+			if c := compareValues(x[i].Value, y[i].Value); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(int64(len(x)), int64(len(y)))
+	case bson.A:
+		y := b.(bson.A)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareValues(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(int64(len(x)), int64(len(y)))
+	case primitive.Binary:
+		y := b.(primitive.Binary)
+		if len(x.Data) != len(y.Data) {
+			return cmpInt(int64(len(x.Data)), int64(len(y.Data)))
+		}
+		if x.Subtype != y.Subtype {
+			return cmpInt(int64(x.Subtype), int64(y.Subtype))
+		}
+		return bytes.Compare(x.Data, y.Data)
+	case primitive.ObjectID:
+		y := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return cmpInt(int64(x), int64(b.(primitive.DateTime)))
+	case primitive.Timestamp:
+		return primitive.CompareTimestamp(x, b.(primitive.Timestamp))
+	case primitive.Regex:
+		y := b.(primitive.Regex)
+		return strings.Compare(x.Pattern+"/"+x.Options, y.Pattern+"/"+y.Options)
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toInt64(v any) (int64, bool) {
+	switch t := v.(type) {
+	case int32:
+		return int64(t), true
+	case int64:
+		return t, true
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+			return int64(t), true
+		}
+	}
+	return 0, false
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareBySort compares two documents according to a sort specification.
+func compareBySort(a, b bson.D, spec bson.D) int {
+	for _, e := range spec {
+		va, _ := getPath(a, e.Key)
+		vb, _ := getPath(b, e.Key)
+		c := compareValues(va, vb)
+		if toFloat(e.Value) < 0 {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// projectDocument applies an inclusion or exclusion projection.
+func projectDocument(doc bson.D, projection bson.D) bson.D {
+	if len(projection) == 0 {
+		return doc
+	}
+
+	include := false
+	excludeID := false
+	for _, e := range projection {
+		if e.Key == "_id" {
+			excludeID = !truthy(e.Value)
+			continue
+		}
+		if truthy(e.Value) {
+			include = true
+		}
+	}
+
+	if !include {
+		out := copyValue(doc).(bson.D)
+		for _, e := range projection {
+			if !truthy(e.Value) {
+				out = unsetPath(out, e.Key)
+			}
+		}
+		return out
+	}
+
+	out := bson.D{}
+	if id, ok := docGet(doc, "_id"); ok && !excludeID {
+		out = append(out, bson.E{Key: "_id", Value: id})
+	}
+	for _, e := range projection {
+		if e.Key == "_id" || !truthy(e.Value) {
+			continue
+		}
+		if v, ok := getPath(doc, e.Key); ok {
+			out, _ = setPath(out, e.Key, copyValue(v))
+		}
+	}
+	return out
+}
+
+// upsertDocument builds the base document of an upsert from the equality conditions of a filter.
+func upsertDocument(filter bson.D) bson.D {
+	out := bson.D{}
+	for _, e := range filter {
+		if e.Key == "$and" {
+			if arr, ok := e.Value.(bson.A); ok {
+				for _, v := range arr {
+					if sub, ok := v.(bson.D); ok {
+						for _, se := range upsertDocument(sub) {
+							out, _ = setPath(out, se.Key, se.Value)
+						}
+					}
+				}
+			}
+			continue
+		}
+		if isOperator(e.Key) {
+			continue
+		}
+
+		v := e.Value
+		if ops, ok := operatorDocument(v); ok {
+			eq, found := docGet(ops, "$eq")
+			if !found {
+				continue
+			}
+			v = eq
+		}
+		out, _ = setPath(out, e.Key, copyValue(v))
+	}
+	return out
+}
+
+// applyUpdate returns a copy of doc with the update operators applied.
+// insert is true when the update creates a document through an upsert.
+func applyUpdate(doc bson.D, update bson.D, insert bool) (bson.D, error) {
+	out := copyValue(doc).(bson.D)
+	id, hasID := docGet(out, "_id")
+
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("modifier %s must be an object", op.Key)
+		}
+
+		for _, f := range fields {
+			var err error
+			switch op.Key {
+			case "$set":
+				out, err = setPath(out, f.Key, copyValue(f.Value))
+			case "$setOnInsert":
+				if insert {
+					out, err = setPath(out, f.Key, copyValue(f.Value))
+				}
+			case "$unset":
+				out = unsetPath(out, f.Key)
+			case "$inc":
+				cur, found := getPath(out, f.Key)
+				if !found || cur == nil {
+					out, err = setPath(out, f.Key, f.Value)
+					break
+				}
+				sum, ok := addNumbers(cur, f.Value)
+				if !ok {
+					return nil, fmt.Errorf("cannot apply $inc to a value of non-numeric type: %s", f.Key)
+				}
+				out, err = setPath(out, f.Key, sum)
+			case "$min", "$max":
+				cur, found := getPath(out, f.Key)
+				c := compareValues(f.Value, cur)
+				if !found || op.Key == "$min" && c < 0 || op.Key == "$max" && c > 0 {
+					out, err = setPath(out, f.Key, copyValue(f.Value))
+				}
+			case "$currentDate":
+				out, err = setPath(out, f.Key, currentDate(f.Value))
+			case "$rename":
+				to, ok := f.Value.(string)
+				if !ok {
+					return nil, fmt.Errorf("the $rename target of %s must be a string", f.Key)
+				}
+				if cur, found := getPath(out, f.Key); found {
+					out = unsetPath(out, f.Key)
+					out, err = setPath(out, to, cur)
+				}
+			case "$push", "$addToSet", "$pull":
+				out, err = updateArray(out, op.Key, f.Key, f.Value)
+			default:
+				return nil, fmt.Errorf("memory backend: unsupported update operator %s", op.Key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if newID, ok := docGet(out, "_id"); hasID && (!ok || !valuesEqual(newID, id)) {
+		return nil, immutableIDError()
+	}
+	return out, nil
+}
+
+// currentDate returns the value of a $currentDate field: true or {$type: "date"} for the current
+// date, {$type: "timestamp"} for a timestamp.
+func currentDate(spec any) any {
+	t := time.Now()
+	if d, ok := spec.(bson.D); ok {
+		if typ, _ := docGet(d, "$type"); typ == "timestamp" {
+			return primitive.Timestamp{T: uint32(t.Unix())}
+		}
+	}
+	return primitive.NewDateTimeFromTime(t)
+}
+
+// updateArray applies a $push, $addToSet or $pull to the array at path.
+// $push and $addToSet accept {$each: [...]} to add several values.
+func updateArray(doc bson.D, op, path string, value any) (bson.D, error) {
+	cur, found := getPath(doc, path)
+	arr, ok := cur.(bson.A)
+	if found && cur != nil && !ok {
+		return nil, fmt.Errorf("the field %s must be an array but is of type %T", path, cur)
+	}
+	if !found && op == "$pull" {
+		return doc, nil
+	}
+
+	values := bson.A{value}
+	if d, ok := value.(bson.D); ok && op != "$pull" {
+		if each, found := docGet(d, "$each"); found {
+			if values, ok = each.(bson.A); !ok {
+				return nil, fmt.Errorf("the $each of %s must be an array", path)
+			}
+		}
+	}
+
+	out := make(bson.A, 0, len(arr)+len(values))
+	switch op {
+	case "$push":
+		out = append(append(out, arr...), values...)
+	case "$addToSet":
+		out = append(out, arr...)
+		for _, v := range values {
+			if !containsValue(out, v) {
+				out = append(out, v)
+			}
+		}
+	case "$pull":
+		for _, e := range arr {
+			matched, err := pullMatch(e, value)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				out = append(out, e)
+			}
+		}
+	}
+	return setPath(doc, path, copyValue(out))
+}
+
+// pullMatch reports whether a $pull condition removes the array element e.
+// The condition is a value, a document of query operators or a query on document elements.
+func pullMatch(e, cond any) (bool, error) {
+	if ops, ok := operatorDocument(cond); ok {
+		return matchOperators([]any{e}, true, ops)
+	}
+	if q, ok := cond.(bson.D); ok {
+		if doc, ok := e.(bson.D); ok {
+			return matchDocument(doc, q)
+		}
+	}
+	return valuesEqual(e, cond), nil
+}
+
+func containsValue(arr bson.A, v any) bool {
+	for _, e := range arr {
+		if valuesEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// addNumbers adds two BSON numbers, widening the result type like MongoDB does.
+func addNumbers(a, b any) (any, bool) {
+	if typeOrder(a) != 3 || typeOrder(b) != 3 {
+		return nil, false
+	}
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+	if aFloat || bFloat {
+		return toFloat(a) + toFloat(b), true
+	}
+
+	ia, _ := toInt64(a)
+	ib, _ := toInt64(b)
+	sum := ia + ib
+	_, a32 := a.(int32)
+	_, b32 := b.(int32)
+	if a32 && b32 && sum >= math.MinInt32 && sum <= math.MaxInt32 {
+		return int32(sum), true
+	}
+	return sum, true
+}
@@ -0,0 +1,225 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type memUser struct {
+	ID        string     `bson:"_id"`
+	Name      string     `bson:"name" db:"unique"`
+	Age       int64      `bson:"age" db:"index"`
+	Tags      []string   `bson:"tags,omitempty"`
+	CreatedAt *time.Time `bson:"created_at,omitempty"`
+}
+
+func TestMemoryDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	defer db.Close()
+
+	require.NoError(t, db.Indexes(ctx, &memUser{}))
+
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		user := &memUser{ID: name, Name: name, Age: int64(20 + i*10), Tags: []string{"t" + name[:1]}}
+		require.NoError(t, db.Set(user))
+	}
+
+	// get
+	user := &memUser{}
+	require.NoError(t, db.Unmarshal("bob", user))
+	require.Equal(t, int64(30), user.Age)
+	require.ErrorIs(t, db.Unmarshal("nobody", user), mongo.ErrRecordNotFound)
+
+	// unique index
+	require.ErrorIs(t, db.Set(&memUser{ID: "bob2", Name: "bob"}), mongo.ErrDuplicateKey)
+
+	// query operators, sort and projection
+	q := mongo.Where("age").Gte(30).And(mongo.Where("name").In("bob", "carol", "dave"))
+	record, err := db.First(&memUser{}, q, mongo.Desc("age"), mongo.Include("name"))
+	require.NoError(t, err)
+	require.Equal(t, mongo.Map().Set("_id", "dave").Set("name", "dave"), record)
+
+	count, err := db.Count(&memUser{}, mongo.Or(mongo.Where("tags").Eq("ta"), mongo.Where("name").Regex("^CA", "i")))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	count, err = db.Count(&memUser{}, mongo.Not(mongo.Where("age").Lt(40)))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	// pagination
+	total, list, err := db.Pagination(&memUser{}, nil, mongo.Asc("age"), 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), total)
+	require.Len(t, list, 1)
+	require.Equal(t, "dave", list[0]["_id"])
+
+	// update and inc
+	newRecord, err := db.Update(&memUser{ID: "alice", Name: "alice", Age: 21})
+	require.NoError(t, err)
+	require.Equal(t, int64(21), newRecord["age"])
+
+	err = db.Txn(ctx, func(txn *mongo.Txn) error {
+		_, err := txn.Model(&memUser{}).Update(mongo.Map().Set("_id", "nobody").Set("age", 1))
+		require.ErrorIs(t, err, mongo.ErrRecordNotFound)
+
+		return txn.Model(&memUser{}).Inc("alice", mongo.Map().Set("age", 2))
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Unmarshal("alice", user))
+	require.Equal(t, int64(23), user.Age)
+
+	// list in batches
+	var ids []string
+	err = db.Txn(ctx, func(txn *mongo.Txn) error {
+		return txn.Model(&memUser{}).ListByCursor(nil, true, 3, func(m mongo.M) (bool, error) {
+			ids = append(ids, m["_id"].(string))
+			return true, nil
+		})
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"dave", "carol", "bob", "alice"}, ids)
+
+	// typed collection
+	err = db.Txn(ctx, func(txn *mongo.Txn) error {
+		users, err := mongo.NewCollection[memUser](txn).Find(mongo.Where("age").Gt(25), mongo.Asc("age"))
+		if err != nil {
+			return err
+		}
+		require.Len(t, users, 3)
+		require.Equal(t, "bob", users[0].Name)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// delete
+	require.NoError(t, db.Delete(&memUser{}, "dave"))
+	exists, err := db.Has(&memUser{}, "dave")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestMemoryTxnRollback(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	require.NoError(t, db.Set(&memUser{ID: "1", Name: "one"}))
+
+	errAbort := errors.New("abort")
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		if err := txn.Model(&memUser{}).Set(&memUser{ID: "2", Name: "two"}); err != nil {
+			return err
+		}
+		if err := txn.Model(&memUser{}).Del("1"); err != nil {
+			return err
+		}
+		return errAbort
+	}, true)
+	require.ErrorIs(t, err, errAbort)
+
+	count, err := db.Count(&memUser{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	exists, err := db.Has(&memUser{}, "1")
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestMemoryAggregate(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	for i, name := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, db.Set(&memUser{ID: name, Name: name, Age: int64(20 + i*10)}))
+	}
+
+	pipeline := mongo.NewPipeline().
+		Match(mongo.Where("age").Gte(30)).
+		Sort(mongo.Desc("age")).
+		Project(mongo.Include("name"))
+
+	var names []string
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		return mongo.Aggregate(txn.Model(&memUser{}), pipeline, func(user *memUser) (bool, error) {
+			names = append(names, user.Name)
+			return true, nil
+		})
+	}, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"carol", "bob"}, names)
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	store := mongo.NewTokenStore(db, "user_resume_token")
+	token, err := store.LoadToken(ctx, "user")
+	require.NoError(t, err)
+	require.Nil(t, token)
+
+	raw, err := bson.Marshal(bson.D{{Key: "_data", Value: "8263"}})
+	require.NoError(t, err)
+	require.NoError(t, store.SaveToken(ctx, "user", raw))
+
+	token, err = store.LoadToken(ctx, "user")
+	require.NoError(t, err)
+	require.Equal(t, bson.Raw(raw), token)
+
+	err = db.Txn(ctx, func(txn *mongo.Txn) error {
+		return txn.Model(&memUser{}).Watch(ctx, nil, func(e mongo.ChangeEvent) (bool, error) {
+			return false, nil
+		})
+	})
+	require.ErrorIs(t, err, mongo.ErrNotSupported)
+}
+
+func TestMemoryBulk(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	require.NoError(t, db.Indexes(ctx, &memUser{}))
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&memUser{})
+
+		res, err := model.BulkSet([]any{
+			&memUser{ID: "1", Name: "one"},
+			&memUser{ID: "2", Name: "two"},
+			&memUser{ID: "3", Name: "three"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(3), res.Upserted)
+
+		// unordered: the duplicate fails, the rest is applied
+		res, err = model.Bulk().
+			BatchSize(2).
+			Ordered(false).
+			Set(&memUser{ID: "4", Name: "one"}, &memUser{ID: "5", Name: "five"}).
+			Inc("1", mongo.Map().Set("age", 1)).
+			Delete("3").
+			Do()
+		require.ErrorIs(t, err, mongo.ErrDuplicateKey)
+		require.Len(t, res.Errors, 1)
+		require.Equal(t, 0, res.Errors[0].Index)
+		require.Equal(t, int64(1), res.Upserted)
+		require.Equal(t, int64(1), res.Modified)
+		require.Equal(t, int64(1), res.Deleted)
+
+		_, err = model.BulkSet([]any{&memUser{Name: "no id"}})
+		require.ErrorIs(t, err, mongo.ErrNoID)
+		return nil
+	})
+	require.NoError(t, err)
+
+	count, err := db.Count(&memUser{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+}
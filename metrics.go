@@ -0,0 +1,191 @@
+// Package mongo provides metrics for operations, transactions and the connection pool.
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Metric names recorded by Metrics, Database.Instrument and PoolMonitor.
+const (
+	// MetricOperations counts Model operations by collection and op.
+	MetricOperations = "mongo_operations_total"
+	// MetricOperationDuration observes the duration in seconds of Model operations by collection and op.
+	MetricOperationDuration = "mongo_operation_duration_seconds"
+	// MetricOperationErrors counts failed Model operations by collection, op and class (see ErrorClass).
+	MetricOperationErrors = "mongo_operation_errors_total"
+	// MetricTxnCommits counts committed multi-document transactions.
+	MetricTxnCommits = "mongo_txn_commits_total"
+	// MetricTxnAborts counts aborted multi-document transactions.
+	MetricTxnAborts = "mongo_txn_aborts_total"
+	// MetricTxnRetries counts transaction attempts retried after a transient error.
+	MetricTxnRetries = "mongo_txn_retries_total"
+	// MetricPoolEvents counts connection pool events by event type.
+	MetricPoolEvents = "mongo_pool_events_total"
+	// MetricPoolCheckoutDuration observes the duration in seconds of connection checkouts.
+	MetricPoolCheckoutDuration = "mongo_pool_checkout_duration_seconds"
+)
+
+// Labels are the dimensions of a metric.
+type Labels map[string]string
+
+// Recorder receives metrics. Implement it to export them to Prometheus or another
+// metrics library; MetricsRegistry keeps them in memory.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// Add increases the counter name with the given labels by delta.
+	Add(name string, labels Labels, delta float64)
+
+	// Observe records a value of the histogram name with the given labels.
+	Observe(name string, labels Labels, value float64)
+}
+
+// ErrorClass returns the metric class of an operation error:
+// not_found, duplicate_key, version_conflict, timeout or other, and an empty string for nil.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRecordNotFound):
+		return "not_found"
+	case errors.Is(err, ErrDuplicateKey) || isDuplicateKeyError(err):
+		return "duplicate_key"
+	case errors.Is(err, ErrVersionConflict):
+		return "version_conflict"
+	case errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err):
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+// Metrics returns a middleware recording the count, duration and errors of every Model operation.
+// List and ListByCursor are recorded once per page fetch.
+func Metrics(r Recorder) Middleware {
+	return func(next Handler) Handler {
+		return func(op *Operation) (any, error) {
+			start := time.Now()
+			res, err := next(op)
+
+			labels := Labels{"collection": op.Collection, "op": op.Name}
+			r.Add(MetricOperations, labels, 1)
+			r.Observe(MetricOperationDuration, labels, time.Since(start).Seconds())
+			if err != nil {
+				r.Add(MetricOperationErrors, Labels{"collection": op.Collection, "op": op.Name, "class": ErrorClass(err)}, 1)
+			}
+			return res, err
+		}
+	}
+}
+
+// Instrument records the operations and multi-document transactions of the database with r.
+//
+// Example:
+//
+//	registry := mongo.NewMetricsRegistry()
+//	db.Instrument(registry)
+func (d *Database) Instrument(r Recorder) {
+	d.Use(Metrics(r))
+	d.recorder = r
+}
+
+// recordTxn records the outcome of a multi-document transaction that took attempts tries.
+func (d *Database) recordTxn(attempts int, err error) {
+	if d.recorder == nil {
+		return
+	}
+	if attempts > 1 {
+		d.recorder.Add(MetricTxnRetries, nil, float64(attempts-1))
+	}
+	if err != nil {
+		d.recorder.Add(MetricTxnAborts, nil, 1)
+		return
+	}
+	d.recorder.Add(MetricTxnCommits, nil, 1)
+}
+
+// PoolMonitor returns a driver pool monitor recording connection pool events with r.
+// Set it with ClientOptions.SetPoolMonitor, or set ConnectOptions.Metrics to install it with Instrument.
+func PoolMonitor(r Recorder) *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			r.Add(MetricPoolEvents, Labels{"event": e.Type}, 1)
+			if e.Type == event.GetSucceeded {
+				r.Observe(MetricPoolCheckoutDuration, nil, e.Duration.Seconds())
+			}
+		},
+	}
+}
+
+// MetricsRegistry is an in-process Recorder, useful in tests and for simple exporters.
+type MetricsRegistry struct {
+	mu         sync.Mutex
+	counters   map[string]float64
+	histograms map[string][]float64
+}
+
+// NewMetricsRegistry creates an empty registry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{counters: make(map[string]float64), histograms: make(map[string][]float64)}
+}
+
+// Add implements Recorder.
+func (r *MetricsRegistry) Add(name string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[metricKey(name, labels)] += delta
+}
+
+// Observe implements Recorder.
+func (r *MetricsRegistry) Observe(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := metricKey(name, labels)
+	r.histograms[key] = append(r.histograms[key], value)
+}
+
+// Counter returns the value of a counter, zero if it was never increased.
+func (r *MetricsRegistry) Counter(name string, labels Labels) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[metricKey(name, labels)]
+}
+
+// Observations returns a copy of the values observed by a histogram.
+func (r *MetricsRegistry) Observations(name string, labels Labels) []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]float64(nil), r.histograms[metricKey(name, labels)]...)
+}
+
+// metricKey renders a metric in the Prometheus text format, e.g. name{a="1",b="2"}.
+func metricKey(name string, labels Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, "%s=%q", k, labels[k])
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
@@ -0,0 +1,64 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMemoryMetrics(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	require.NoError(t, db.Indexes(ctx, &memUser{}))
+
+	registry := mongo.NewMetricsRegistry()
+	db.Instrument(registry)
+
+	require.NoError(t, db.Set(&memUser{ID: "1", Name: "alice"}))
+	require.NoError(t, db.Set(&memUser{ID: "2", Name: "bob"}))
+	require.ErrorIs(t, db.Set(&memUser{ID: "3", Name: "bob"}), mongo.ErrDuplicateKey)
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&memUser{})
+		_, err := model.Get("missing")
+		require.ErrorIs(t, err, mongo.ErrRecordNotFound)
+		return model.ListByCursor(nil, false, 1, func(m mongo.M) (bool, error) { return true, nil })
+	}, true)
+	require.NoError(t, err)
+	err = db.Txn(ctx, func(txn *mongo.Txn) error {
+		return errors.New("abort")
+	}, true)
+	require.Error(t, err)
+
+	set := mongo.Labels{"collection": "mem_user", "op": "Set"}
+	require.Equal(t, 3.0, registry.Counter(mongo.MetricOperations, set))
+	require.Len(t, registry.Observations(mongo.MetricOperationDuration, set), 3)
+	require.Equal(t, 1.0, registry.Counter(mongo.MetricOperationErrors,
+		mongo.Labels{"collection": "mem_user", "op": "Set", "class": "duplicate_key"}))
+	require.Equal(t, 1.0, registry.Counter(mongo.MetricOperationErrors,
+		mongo.Labels{"collection": "mem_user", "op": "Get", "class": "not_found"}))
+	// two pages of one document and the empty last page
+	require.Equal(t, 3.0, registry.Counter(mongo.MetricOperations, mongo.Labels{"collection": "mem_user", "op": "List"}))
+	require.Equal(t, 1.0, registry.Counter(mongo.MetricTxnCommits, nil))
+	require.Equal(t, 1.0, registry.Counter(mongo.MetricTxnAborts, nil))
+
+	monitor := mongo.PoolMonitor(registry)
+	monitor.Event(&event.PoolEvent{Type: event.ConnectionCreated})
+	monitor.Event(&event.PoolEvent{Type: event.GetSucceeded, Duration: 2 * time.Millisecond})
+	require.Equal(t, 1.0, registry.Counter(mongo.MetricPoolEvents, mongo.Labels{"event": event.ConnectionCreated}))
+	require.Equal(t, []float64{0.002}, registry.Observations(mongo.MetricPoolCheckoutDuration, nil))
+}
+
+func TestErrorClass(t *testing.T) {
+	require.Equal(t, "", mongo.ErrorClass(nil))
+	require.Equal(t, "not_found", mongo.ErrorClass(mongo.ErrRecordNotFound))
+	require.Equal(t, "duplicate_key", mongo.ErrorClass(mongo.ErrDuplicateKey))
+	require.Equal(t, "version_conflict", mongo.ErrorClass(mongo.ErrVersionConflict))
+	require.Equal(t, "timeout", mongo.ErrorClass(context.DeadlineExceeded))
+	require.Equal(t, "other", mongo.ErrorClass(errors.New("boom")))
+}
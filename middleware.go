@@ -0,0 +1,104 @@
+// Package mongo provides the middleware chain that every Model operation passes through.
+package mongo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Operation describes a Model call passing through the middleware chain.
+// Middleware may change its fields before calling the next handler; the operation
+// is executed with the values it has when it reaches the end of the chain.
+type Operation struct {
+	// Name is the Model method, e.g. "Set", "Get", "Count" or "List" (one per page fetch).
+	Name string
+
+	// Collection is the collection name, as derived by GetModelName.
+	Collection string
+
+	// Filter selects the documents. ID based methods use the ID filter of GetIDFilter.
+	Filter any
+
+	// Update is the record or fields written by Set, Update, UpdateMany and Inc.
+	Update any
+
+	// Sort and Projection are the sort and projection specs, if any.
+	Sort       any
+	Projection any
+
+	// Pipeline is the pipeline of Aggregate.
+	Pipeline any
+
+	// Options holds method specific values such as "page", "page_size" and "limit".
+	Options M
+
+	// Ctx is the context the operation runs with, the transaction context by default.
+	Ctx context.Context
+
+	// Txn is the transaction of the Model.
+	Txn *Txn
+}
+
+// Handler executes an operation and returns its result: M for single documents, []M for
+// lists, int64 for counts, bool for Has, *BulkResult for bulk writes and nil otherwise.
+type Handler func(op *Operation) (any, error)
+
+// Middleware wraps a handler. It can modify the operation, observe the result and duration
+// of next, or short-circuit the call by returning a result of the expected type without calling next.
+//
+// Example:
+//
+//	db.Use(func(next mongo.Handler) mongo.Handler {
+//	    return func(op *mongo.Operation) (any, error) {
+//	        start := time.Now()
+//	        res, err := next(op)
+//	        log.Println(op.Name, op.Collection, time.Since(start), err)
+//	        return res, err
+//	    }
+//	})
+type Middleware func(next Handler) Handler
+
+// Use appends middleware to the chain of all Model operations of the database.
+// The first middleware is the outermost. Use is not safe to call concurrently with operations.
+func (d *Database) Use(mw ...Middleware) {
+	d.middlewares = append(d.middlewares, mw...)
+}
+
+// run passes op through the middleware chain and executes it with fn, which receives the
+// model bound to the operation's context.
+func run[T any](m *Model, op *Operation, fn func(m *Model, op *Operation) (T, error)) (T, error) {
+	op.Collection = m.coll.Name()
+	op.Txn = m.txn
+	if op.Ctx == nil {
+		op.Ctx = m.txn.ctx
+	}
+
+	chain := m.txn.db.middlewares
+	if len(chain) == 0 {
+		return fn(m.withContext(op.Ctx), op)
+	}
+
+	var handler Handler = func(op *Operation) (any, error) {
+		return fn(m.withContext(op.Ctx), op)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+
+	res, err := handler(op)
+	v, ok := res.(T)
+	if !ok && res != nil && err == nil {
+		return v, fmt.Errorf("middleware returned %T for %s, want %T", res, op.Name, v)
+	}
+	return v, err
+}
+
+// withContext returns the model running with ctx instead of the transaction context.
+func (m *Model) withContext(ctx context.Context) *Model {
+	if ctx == m.txn.ctx {
+		return m
+	}
+	c := *m
+	c.txn = &Txn{ctx: ctx, db: m.txn.db}
+	return &c
+}
@@ -0,0 +1,73 @@
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMemoryMiddleware(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	var ops []string
+	db.Use(func(next mongo.Handler) mongo.Handler {
+		return func(op *mongo.Operation) (any, error) {
+			ops = append(ops, op.Name+":"+op.Collection)
+			return next(op)
+		}
+	})
+	// tenant filter on reads
+	db.Use(func(next mongo.Handler) mongo.Handler {
+		return func(op *mongo.Operation) (any, error) {
+			if op.Name == "Find" || op.Name == "Count" {
+				op.Filter = mongo.Where("age").Gte(18)
+			}
+			return next(op)
+		}
+	})
+	// cache short-circuit
+	db.Use(func(next mongo.Handler) mongo.Handler {
+		return func(op *mongo.Operation) (any, error) {
+			if op.Name == "Get" && op.Filter.(bson.D)[0].Value == "cached" {
+				return mongo.Map().Set("_id", "cached"), nil
+			}
+			if op.Name == "Has" {
+				return "wrong type", nil
+			}
+			return next(op)
+		}
+	})
+
+	require.NoError(t, db.Set(&memUser{ID: "1", Name: "kid", Age: 10}))
+	require.NoError(t, db.Set(&memUser{ID: "2", Name: "adult", Age: 30}))
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&memUser{})
+
+		list, err := model.Find(nil, nil)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		count, err := model.Count(nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+
+		doc, err := model.Get("cached")
+		require.NoError(t, err)
+		require.Equal(t, "cached", doc["_id"])
+
+		_, err = model.Has("1")
+		require.Error(t, err)
+
+		require.NoError(t, model.List(nil, func(m mongo.M) (bool, error) { return true, nil }))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Set:mem_user", "Set:mem_user", "Find:mem_user", "Count:mem_user",
+		"Get:mem_user", "Has:mem_user", "List:mem_user",
+	}, ops)
+}
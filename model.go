@@ -3,7 +3,6 @@ package mongo
 
 import (
 	"errors"
-	"reflect"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -13,194 +12,314 @@ import (
 // Model represents a MongoDB collection with transaction context.
 // It provides low-level operations for database interactions.
 type Model struct {
-	txn  *Txn
-	coll *mongo.Collection
+	txn    *Txn
+	coll   Store
+	schema *schema
+	scope  deleteScope
+
+	// skipAfterFind leaves AfterFind hooks to the caller, which decodes the documents itself.
+	skipAfterFind bool
 }
 
 // Set creates or updates a document in the collection (upsert operation).
 // The model must have a valid ID field.
+// Fields tagged `db:"updated_at"` are set to the current time, and fields tagged `db:"created_at"`
+// only when the document is inserted; in that case fields missing from the record are kept.
+// With a `db:"version"` field the stored version must equal the record's, otherwise
+// ErrVersionConflict is returned; on success the record holds the increased version.
 func (m *Model) Set(model any) error {
-	id := GetID(model)
-	if id == nil || id == "" {
-		return ErrNoID
+	op := &Operation{Name: "Set", Filter: GetIDFilter(GetID(model)), Update: model}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		return nil, m.set(op.Filter, op.Update)
+	})
+	return err
+}
+
+func (m *Model) set(filter, model any) error {
+	if err := m.beforeSave(model); err != nil {
+		return err
+	}
+
+	w, err := m.prepareSet(model, filter)
+	if err != nil {
+		return err
 	}
 
-	_, err := m.coll.ReplaceOne(m.txn.ctx, GetIDFilter(id), model, options.Replace().SetUpsert(true))
-	if err != nil && isDuplicateKeyError(err) {
-		return ErrDuplicateKey
+	var stored M
+	if w.replacement != nil {
+		_, err = m.coll.ReplaceOne(m.txn.ctx, w.filter, w.replacement, options.Replace().SetUpsert(true))
+	} else {
+		// read the creation time back, as the stored document may predate the record's
+		opt := options.FindOneAndUpdate().
+			SetUpsert(true).
+			SetReturnDocument(options.After).
+			SetProjection(bson.D{{Key: m.schema.createdAt.name, Value: 1}})
+		err = m.coll.FindOneAndUpdate(m.txn.ctx, w.filter, w.update, opt).Decode(&stored)
 	}
-	return err
+	if err != nil {
+		if w.versioned && isIDConflictError(err) {
+			return ErrVersionConflict
+		}
+		if isDuplicateKeyError(err) {
+			return ErrDuplicateKey
+		}
+		return err
+	}
+
+	if w.versioned {
+		m.schema.version.setInt(model, w.version)
+	}
+	if f := m.schema.createdAt; f != nil {
+		if created, ok := stored[f.name]; ok {
+			f.setStoredTime(model, created)
+		}
+	}
+	return m.afterSave(model)
 }
 
 // Del removes a document from the collection by its ID.
+// For models with a `db:"deleted_at"` field the document is kept and marked as deleted; see Purge.
 func (m *Model) Del(id any) error {
-	_, err := m.coll.DeleteOne(m.txn.ctx, GetIDFilter(id))
+	op := &Operation{Name: "Del", Filter: GetIDFilter(id)}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		if err := m.beforeDelete(op.Filter); err != nil {
+			return nil, err
+		}
+
+		if m.softDeletes() {
+			w := m.softDelete(op.Filter).(*mongo.UpdateOneModel)
+			_, err := m.coll.UpdateOne(m.txn.ctx, w.Filter, w.Update)
+			return nil, err
+		}
+		_, err := m.coll.DeleteOne(m.txn.ctx, op.Filter)
+		return nil, err
+	})
 	return err
 }
 
 // Update updates a record with the given data. The parameter 'update' can be a structure or a Map containing the primary key.
-func (m *Model) Update(update any) (newRecord M, err error) {
-	id := GetID(update)
-	if id == nil || id == "" {
-		return nil, ErrNoID
-	}
+// Fields tagged `db:"updated_at"` are set to the current time and `db:"created_at"` fields are left unchanged.
+// A `db:"version"` field is increased; if the update carries a version that no longer matches
+// the stored one, ErrVersionConflict is returned.
+// The stored document after the update is returned, or the one before it with UpdateOptions.ReturnBefore;
+// a nil document is returned if there was none before because the update upserted it.
+// Map keys can be dotted paths such as "address.city".
+func (m *Model) Update(update any, opts ...func(o *UpdateOptions)) (newRecord M, err error) {
+	opt := &UpdateOptions{}
+	for _, v := range opts {
+		v(opt)
+	}
+
+	op := &Operation{
+		Name:    "Update",
+		Filter:  GetIDFilter(GetID(update)),
+		Update:  update,
+		Options: Map().Set("upsert", opt.Upsert).Set("return_before", opt.ReturnBefore),
+	}
+	return run(m, op, func(m *Model, op *Operation) (M, error) {
+		return m.update(op.Filter, op.Update, opt)
+	})
+}
 
-	raw, err := bson.Marshal(update)
-	if err != nil {
+func (m *Model) update(filter, update any, opt *UpdateOptions) (M, error) {
+	if err := m.beforeUpdate(update); err != nil {
 		return nil, err
 	}
-	updateMap := Map()
-	if err := bson.Unmarshal(raw, &updateMap); err != nil {
+
+	w, err := m.prepareUpdate(update, filter)
+	if err != nil {
 		return nil, err
 	}
 
-	res := m.coll.FindOneAndUpdate(m.txn.ctx, GetIDFilter(id), bson.D{{Key: "$set", Value: updateMap}})
-	old := Map()
-	err = res.Decode(&old)
+	findOpt := options.FindOneAndUpdate().SetUpsert(opt.Upsert).SetReturnDocument(options.After)
+	if opt.ReturnBefore {
+		findOpt.SetReturnDocument(options.Before)
+	}
+	res := m.coll.FindOneAndUpdate(m.txn.ctx, m.scoped(w.filter), w.update, findOpt)
+	doc := Map()
+	err = res.Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) && opt.Upsert {
+		// only an upsert returning the document before it inserted one finds nothing
+		doc, err = nil, nil
+	}
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
+			if w.versioned {
+				count, err := m.coll.CountDocuments(m.txn.ctx, m.scoped(GetIDFilter(GetID(update))), options.Count().SetLimit(1))
+				if err != nil {
+					return nil, err
+				}
+				if count > 0 {
+					return nil, ErrVersionConflict
+				}
+			}
 			return nil, ErrRecordNotFound
 		}
+		// a stale version makes an upsert insert a document with the existing _id
+		if w.versioned && isIDConflictError(err) {
+			return nil, ErrVersionConflict
+		}
 		if isDuplicateKeyError(err) {
 			return nil, ErrDuplicateKey
 		}
 		return nil, err
 	}
 
-	for k, v := range updateMap {
-		old.Set(k, v)
+	if f := m.schema.version; f != nil {
+		version, _ := toInt64(doc[f.name])
+		if opt.ReturnBefore {
+			version++
+		}
+		f.setInt(update, version)
 	}
 
-	return old, nil
+	if err := m.afterSave(update); err != nil {
+		return nil, err
+	}
+	return doc, nil
 }
 
 // UpdateMany updates multiple documents matching the filter.
 // Returns the number of documents that were modified.
+// The update is set field by field, or applied as is when it is an Update built with NewUpdate.
+// Fields tagged `db:"updated_at"` are set to the current time.
 func (m *Model) UpdateMany(filter, update any) (updatedCount int64, err error) {
-	raw, err := bson.Marshal(update)
-	if err != nil {
-		return 0, err
-	}
-	updateMap := Map()
-	if err := bson.Unmarshal(raw, &updateMap); err != nil {
-		return 0, err
-	}
+	op := &Operation{Name: "UpdateMany", Filter: filter, Update: update}
+	return run(m, op, func(m *Model, op *Operation) (int64, error) {
+		if u, ok := op.Update.(Update); ok {
+			res, err := m.updateOperators(op.Filter, u, true, false)
+			if err != nil {
+				return 0, err
+			}
+			return res.Modified, nil
+		}
 
-	res, err := m.coll.UpdateMany(m.txn.ctx, filter, bson.D{{Key: "$set", Value: updateMap}})
-	if err != nil {
-		if isDuplicateKeyError(err) {
-			return 0, ErrDuplicateKey
+		updateMap, err := m.updateFields(op.Update)
+		if err != nil {
+			return 0, err
+		}
+
+		res, err := m.coll.UpdateMany(m.txn.ctx, m.scoped(op.Filter), bson.D{{Key: "$set", Value: updateMap}})
+		if err != nil {
+			if isDuplicateKeyError(err) {
+				return 0, ErrDuplicateKey
+			}
+			return 0, err
 		}
-		return 0, err
-	}
 
-	return res.ModifiedCount, nil
+		return res.ModifiedCount, nil
+	})
 }
 
 // Inc atomically increments numeric fields in a document.
 // The fields parameter should be a map of field names to increment values.
 func (m *Model) Inc(id, fields any) error {
-	_, err := m.coll.UpdateByID(m.txn.ctx, id, bson.D{{Key: "$inc", Value: fields}})
+	op := &Operation{Name: "Inc", Filter: GetIDFilter(id), Update: fields}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		_, err := m.coll.UpdateOne(m.txn.ctx, m.scoped(op.Filter), bson.D{{Key: "$inc", Value: op.Update}})
+		return nil, err
+	})
 	return err
 }
 
 // Get retrieves a document by ID with optional field projection.
 // Returns ErrRecordNotFound if the document doesn't exist.
 func (m *Model) Get(id any, projection ...any) (M, error) {
-	opt := options.FindOne()
-	if len(projection) > 0 {
-		opt.SetProjection(projection[0])
-	}
-	res := m.coll.FindOne(m.txn.ctx, GetIDFilter(id), opt)
-	doc := Map()
-	err := res.Decode(&doc)
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, ErrRecordNotFound
-		}
-		return nil, err
-	}
-	return doc, nil
+	op := &Operation{Name: "Get", Filter: GetIDFilter(id), Projection: first(projection)}
+	return run(m, op, func(m *Model, op *Operation) (M, error) {
+		return m.findOne(op)
+	})
 }
 
 // First retrieves the first document matching the filter.
 // Supports sorting and field projection.
 func (m *Model) First(filter, sort any, projection ...any) (M, error) {
-	if filter == nil {
-		filter = bson.D{}
-	}
+	op := &Operation{Name: "First", Filter: filter, Sort: sort, Projection: first(projection)}
+	return run(m, op, func(m *Model, op *Operation) (M, error) {
+		return m.findOne(op)
+	})
+}
 
+// findOne returns the first document matching the operation.
+func (m *Model) findOne(op *Operation) (M, error) {
 	opt := options.FindOne()
-	if sort != nil {
-		opt.SetSort(sort)
+	if op.Sort != nil {
+		opt.SetSort(op.Sort)
 	}
-	if len(projection) > 0 {
-		opt.SetProjection(projection[0])
+	if op.Projection != nil {
+		opt.SetProjection(op.Projection)
 	}
 
-	res := m.coll.FindOne(m.txn.ctx, filter, opt)
-	var v M
-	err := res.Decode(&v)
+	res := m.coll.FindOne(m.txn.ctx, m.scoped(op.Filter), opt)
+	doc := Map()
+	err := res.Decode(&doc)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, ErrRecordNotFound
 		}
 		return nil, err
 	}
-	return v, nil
+	return m.afterFind(doc)
 }
 
 // Unmarshal retrieves a document by ID and unmarshals it into the provided model.
 // Returns ErrRecordNotFound if the document doesn't exist.
 func (m *Model) Unmarshal(id, model any, projection ...any) error {
-	opt := options.FindOne()
-	if len(projection) > 0 {
-		opt.SetProjection(projection[0])
-	}
+	op := &Operation{Name: "Unmarshal", Filter: GetIDFilter(id), Projection: first(projection)}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		opt := options.FindOne()
+		if op.Projection != nil {
+			opt.SetProjection(op.Projection)
+		}
 
-	res := m.coll.FindOne(m.txn.ctx, GetIDFilter(id), opt)
-	err := res.Decode(model)
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return ErrRecordNotFound
+		res := m.coll.FindOne(m.txn.ctx, m.scoped(op.Filter), opt)
+		err := res.Decode(model)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrRecordNotFound
+			}
+			return nil, err
 		}
-	}
+		return nil, runAfterFind(m.txn.ctx, model)
+	})
 	return err
 }
 
 // Count returns the number of documents matching the filter.
 // If filter is nil or empty, returns the estimated document count.
 func (m *Model) Count(filter any) (count int64, err error) {
-	val := reflect.ValueOf(filter)
-	if val.Kind() == reflect.Invalid ||
-		((val.Kind() == reflect.Map ||
-			val.Kind() == reflect.Slice ||
-			val.Kind() == reflect.Array) &&
-			val.Len() < 1) {
+	op := &Operation{Name: "Count", Filter: filter}
+	return run(m, op, func(m *Model, op *Operation) (int64, error) {
+		return m.count(op.Filter)
+	})
+}
+
+func (m *Model) count(filter any) (int64, error) {
+	if isEmptyFilter(filter) && m.scopeFilter() == nil {
 		return m.coll.EstimatedDocumentCount(m.txn.ctx)
 	}
-	return m.coll.CountDocuments(m.txn.ctx, filter)
+	return m.coll.CountDocuments(m.txn.ctx, m.scoped(filter))
 }
 
 // Has checks if a document with the given ID exists.
 // Returns true if the document exists, false otherwise.
 func (m *Model) Has(id any) (bool, error) {
-	count, err := m.coll.CountDocuments(m.txn.ctx, GetIDFilter(id), options.Count().SetLimit(1))
-	return count > 0, err
+	op := &Operation{Name: "Has", Filter: GetIDFilter(id)}
+	return run(m, op, func(m *Model, op *Operation) (bool, error) {
+		count, err := m.coll.CountDocuments(m.txn.ctx, m.scoped(op.Filter), options.Count().SetLimit(1))
+		return count > 0, err
+	})
+}
+
+// pageResult is the result of a Pagination operation.
+type pageResult struct {
+	total int64
+	list  []M
 }
 
 // Pagination retrieves paginated results with total count.
 // Supports filtering, sorting, and field projection.
 func (m *Model) Pagination(filter, sort any, page, pageSize int64, projection ...any) (total int64, list []M, err error) {
-	total, err = m.Count(filter)
-	if err != nil {
-		return
-	}
-
-	if total < 1 {
-		return
-	}
-
 	if page < 1 {
 		page = 1
 	}
@@ -209,59 +328,97 @@ func (m *Model) Pagination(filter, sort any, page, pageSize int64, projection ..
 		pageSize = 1
 	}
 
-	opt := options.Find().SetSkip((page - 1) * pageSize).SetLimit(pageSize)
-	if sort != nil {
-		opt.SetSort(sort)
-	}
-	if len(projection) > 0 {
-		opt.SetProjection(projection[0])
+	op := &Operation{
+		Name:       "Pagination",
+		Filter:     filter,
+		Sort:       sort,
+		Projection: first(projection),
+		Options:    Map().Set("page", page).Set("page_size", pageSize),
 	}
+	res, err := run(m, op, func(m *Model, op *Operation) (p pageResult, err error) {
+		p.total, err = m.count(op.Filter)
+		if err != nil || p.total < 1 {
+			return
+		}
 
-	if filter == nil {
-		filter = bson.D{}
-	}
+		opt := options.Find().SetSkip((page - 1) * pageSize).SetLimit(pageSize)
+		if op.Sort != nil {
+			opt.SetSort(op.Sort)
+		}
+		if op.Projection != nil {
+			opt.SetProjection(op.Projection)
+		}
+		p.list, err = m.find(op.Filter, opt)
+		return
+	})
+	return res.total, res.list, err
+}
+
+// Find retrieves all documents matching the filter.
+// Supports sorting and field projection.
+func (m *Model) Find(filter, sort any, projection ...any) (list []M, err error) {
+	op := &Operation{Name: "Find", Filter: filter, Sort: sort, Projection: first(projection)}
+	return run(m, op, func(m *Model, op *Operation) ([]M, error) {
+		opt := options.Find()
+		if op.Sort != nil {
+			opt.SetSort(op.Sort)
+		}
+		if op.Projection != nil {
+			opt.SetProjection(op.Projection)
+		}
+		return m.find(op.Filter, opt)
+	})
+}
 
-	cursor, err := m.coll.Find(m.txn.ctx, filter, opt)
+// find returns the documents of the model's scope matching filter.
+func (m *Model) find(filter any, opt *options.FindOptions) (list []M, err error) {
+	cursor, err := m.coll.Find(m.txn.ctx, m.scoped(filter), opt)
 	if err != nil {
-		return
+		return nil, err
 	}
-	err = cursor.All(m.txn.ctx, &list)
-	return
+
+	if err := cursor.All(m.txn.ctx, &list); err != nil {
+		return nil, err
+	}
+
+	return m.afterFindAll(list)
 }
 
-// Next retrieves the next page of results using cursor-based pagination.
+// Next retrieves the next page of results using cursor-based pagination on a string _id.
 // This is more efficient for large datasets than offset-based pagination.
+// See PageByCursor for other sorts and backward paging.
 func (m *Model) Next(filter, sort M, lastID string, pageSize int64, projection ...any) (list []M, err error) {
 	if filter == nil {
 		filter = Map()
 	}
 
 	if lastID != "" {
-		filter.Set("_id", Map().Set("$gt", lastID))
+		// copy so the caller's filter is left untouched
+		next := Map()
+		for k, v := range filter {
+			next[k] = v
+		}
+		filter = next.Set("_id", Map().Set("$gt", lastID))
 	}
 
 	if pageSize < 1 {
 		pageSize = 10
 	}
 
-	opt := options.Find().SetLimit(pageSize)
-	if len(projection) > 0 {
-		opt.SetProjection(projection[0])
-	}
+	op := &Operation{Name: "Next", Filter: filter, Projection: first(projection), Options: Map().Set("limit", pageSize)}
 	if sort != nil {
-		opt.SetSort(sort)
-	}
-
-	cursor, err := m.coll.Find(m.txn.ctx, filter, opt)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := cursor.All(m.txn.ctx, &list); err != nil {
-		return nil, err
+		op.Sort = sort
 	}
-
-	return list, nil
+	return run(m, op, func(m *Model, op *Operation) ([]M, error) {
+		opt := options.Find().SetLimit(pageSize)
+		if op.Projection != nil {
+			opt.SetProjection(op.Projection)
+		}
+		if op.Sort != nil {
+			opt.SetSort(op.Sort)
+		}
+		return m.find(op.Filter, opt)
+	})
 }
 
 const defaultListLimit = 100
@@ -280,71 +437,30 @@ func (m *Model) ListDescending(filter M, cb func(m M) (bool, error), projection
 
 // ListByCursor supports efficient traversal of large datasets with cursor-based iteration.
 // Set desc=true for descending order traversal.
+// Each page fetch passes through the middleware chain as a "List" operation.
 func (m *Model) ListByCursor(filter M, desc bool, limit int, cb func(m M) (bool, error), projection ...any) error {
-	nextFilter := Map()
-	for k, v := range filter {
-		nextFilter[k] = v
-	}
-
-	if limit < 1 {
-		limit = defaultListLimit
-	}
-
-	sortOrder := 1
-	cmpOp := "$gt"
-	if desc {
-		sortOrder = -1
-		cmpOp = "$lt"
-	}
-
-	opt := options.Find().SetLimit(int64(limit))
-	if len(projection) > 0 {
-		opt.SetProjection(projection[0])
-	}
-	opt.SetSort(Map().Set("_id", sortOrder))
-
-	next := Map()
-	for {
-		continues, err := func() (bool, error) {
-			cursor, err := m.coll.Find(m.txn.ctx, nextFilter, opt)
-			if err != nil {
-				return false, err
-			}
-			defer cursor.Close(m.txn.ctx)
-
-			last := ""
-			couter := 0
-			for cursor.Next(m.txn.ctx) {
-				m := Map()
-				err := cursor.Decode(&m)
-				if err != nil {
-					return false, err
-				}
-				if ok, err := cb(m); err != nil || !ok {
-					return false, err
-				}
-				id, ok := m.Get("_id")
-				if ok {
-					last, _ = id.(string)
-				}
-
-				couter++
-			}
-
-			if last == "" || couter < limit {
-				return false, nil
-			}
-
-			nextFilter.Set("_id", next.Set(cmpOp, last))
-			return true, nil
-		}()
+	docs := m.All(filter, func(o *IterOptions) {
+		o.Desc = desc
+		o.BatchSize = limit
+		o.Projection = first(projection)
+	})
+	for doc, err := range docs {
 		if err != nil {
 			return err
 		}
-		if !continues {
-			return nil
+		if ok, err := cb(doc); err != nil || !ok {
+			return err
 		}
 	}
+	return nil
+}
+
+// first returns the first optional argument, or nil.
+func first(args []any) any {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return nil
 }
 
 // NewModel creates a new Model instance for the given transaction and model type.
@@ -355,5 +471,5 @@ func NewModel(txn *Txn, model any) *Model {
 		panic(ErrInvalidModelName)
 	}
 
-	return &Model{txn: txn, coll: txn.db.Collection(modelName)}
+	return &Model{txn: txn, coll: txn.db.backend().Collection(modelName), schema: schemaOf(model)}
 }
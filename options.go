@@ -1,7 +1,11 @@
 // Package mongo provides client options configuration.
 package mongo
 
-import "go.mongodb.org/mongo-driver/mongo/options"
+import (
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
 
 // ClientOptions is an alias for the official MongoDB client options.
 // It provides configuration options for MongoDB client connections.
@@ -14,3 +18,42 @@ import "go.mongodb.org/mongo-driver/mongo/options"
 //	    c.SetMaxConnIdleTime(30 * time.Second)
 //	})
 type ClientOptions = options.ClientOptions
+
+// ConnectOptions configures Connect and OpenDatabase.
+// It embeds the driver client options, so client settings can be applied directly.
+//
+// Example:
+//
+//	db, err := mongo.OpenDatabase(ctx, uri, "myapp", func(c *mongo.ConnectOptions) {
+//	    c.SetMaxPoolSize(100)
+//	    c.Ping = &mongo.RetryPolicy{Attempts: 5, Backoff: time.Second, MaxBackoff: 10 * time.Second}
+//	})
+type ConnectOptions struct {
+	*ClientOptions
+
+	// Ping, when set, pings the primary before returning and retries with the given policy.
+	Ping *RetryPolicy
+
+	// Logger, when set, logs every driver command and, for OpenDatabase, every Model operation.
+	Logger *slog.Logger
+
+	// Log configures the records written to Logger.
+	Log LogOptions
+
+	// Metrics, when set, records connection pool events and, for OpenDatabase, operations and transactions.
+	Metrics Recorder
+}
+
+// logOptions returns an option function applying c.Log.
+func (c *ConnectOptions) logOptions(o *LogOptions) {
+	*o = c.Log
+}
+
+// withClientOptions adapts client option functions to a connect option.
+func withClientOptions(opts []func(c *ClientOptions)) func(c *ConnectOptions) {
+	return func(c *ConnectOptions) {
+		for _, v := range opts {
+			v(c.ClientOptions)
+		}
+	}
+}
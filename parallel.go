@@ -0,0 +1,453 @@
+// Package mongo provides parallel collection scans over _id ranges.
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SplitStrategy selects how ParallelList splits the _id space into partitions.
+type SplitStrategy int
+
+const (
+	// SplitSample picks split points from a $sample of the matching _id values. It works for any _id type.
+	SplitSample SplitStrategy = iota
+
+	// SplitSequentialID splits the time range between the smallest and largest _id evenly.
+	// It requires ids generated by SequentialID.
+	SplitSequentialID
+)
+
+// samplesPerPartition is the number of sampled ids per partition for SplitSample.
+const samplesPerPartition = 20
+
+// ParallelOptions configures Model.ParallelList.
+type ParallelOptions struct {
+	// Partitions is the number of _id ranges, four per worker by default.
+	// With fewer partitions than workers, only one worker per partition runs.
+	Partitions int
+
+	// Split selects how split points are chosen, SplitSample by default.
+	Split SplitStrategy
+
+	// BatchSize is the number of documents fetched per page in each partition, 100 by default.
+	BatchSize int
+
+	// Projection limits the returned fields.
+	Projection any
+
+	// Progress, when set, is called after every batch of a partition and when a partition completes.
+	// Calls are serialized.
+	Progress func(p ParallelProgress)
+
+	// Checkpoints, when set, saves the split points and the last _id of every partition, so a
+	// scan that failed or was stopped resumes where each partition stopped. The checkpoints
+	// are reset after a complete scan.
+	Checkpoints TokenStore
+
+	// Key prefixes the checkpoint keys. Defaults to the collection name with the "_parallel" suffix.
+	Key string
+}
+
+// ParallelProgress reports the state of a ParallelList scan.
+type ParallelProgress struct {
+	// Partition is the partition that reported.
+	Partition int
+	// Partitions is the total number of partitions.
+	Partitions int
+	// Completed is the number of completed partitions.
+	Completed int
+	// Processed is the number of documents passed to the callback in this run.
+	Processed int64
+}
+
+// partition is a range of _id values, lo inclusive and hi exclusive. Nil bounds are open.
+type partition struct {
+	lo, hi any
+}
+
+// ParallelList walks the documents matching the filter with up to workers concurrent scans, each over
+// its own _id range. Within a partition, documents are visited in ascending _id order; cb is called
+// concurrently from different partitions. The callback can return false to stop the whole scan.
+// The first error cancels the other partitions and is returned.
+// The scan must not run inside a multi-document transaction.
+//
+// Example:
+//
+//	err := txn.Model(&User{}).ParallelList(ctx, nil, 8, func(m mongo.M) (bool, error) {
+//	    return true, reindex(m)
+//	}, func(o *mongo.ParallelOptions) {
+//	    o.Checkpoints = mongo.NewTokenStore(db, "reindex_checkpoints")
+//	})
+func (m *Model) ParallelList(ctx context.Context, filter M, workers int, cb func(m M) (bool, error), opts ...func(o *ParallelOptions)) error {
+	if workers < 1 {
+		workers = 1
+	}
+	opt := &ParallelOptions{}
+	for _, v := range opts {
+		v(opt)
+	}
+	if opt.Partitions < 1 {
+		opt.Partitions = workers * 4
+	}
+	if workers > opt.Partitions {
+		workers = opt.Partitions
+	}
+	if opt.Key == "" {
+		opt.Key = m.coll.Name() + "_parallel"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m = m.withContext(ctx)
+
+	parts, err := m.partitions(filter, opt)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		once      sync.Once
+		firstErr  error
+		stopped   atomic.Bool
+		mu        sync.Mutex
+		completed int
+		processed atomic.Int64
+	)
+	fail := func(err error) {
+		if stopped.Load() && errors.Is(err, context.Canceled) {
+			// the other partitions fail with the cancellation of a stop
+			return
+		}
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	report := func(i int, done bool) {
+		if opt.Progress == nil && !done {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			completed++
+		}
+		if opt.Progress != nil {
+			opt.Progress(ParallelProgress{Partition: i, Partitions: len(parts), Completed: completed, Processed: processed.Load()})
+		}
+	}
+
+	queue := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				done, err := m.scanPartition(filter, i, parts[i], opt, &processed, report, cb)
+				switch {
+				case err != nil:
+					fail(err)
+				case !done:
+					stopped.Store(true)
+					cancel()
+				default:
+					report(i, true)
+				}
+			}
+		}()
+	}
+
+	for i := range parts {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if stopped.Load() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.resetCheckpoints(ctx, opt, len(parts))
+}
+
+// scanPartition passes the documents of a partition to cb, resuming from its checkpoint.
+// It reports whether the partition was walked to its end.
+func (m *Model) scanPartition(filter M, i int, part partition, opt *ParallelOptions, processed *atomic.Int64,
+	report func(i int, done bool), cb func(m M) (bool, error)) (bool, error) {
+	key := fmt.Sprintf("%s/%d", opt.Key, i)
+
+	// checkpoints are saved even when the scan is cancelled
+	ctx := context.WithoutCancel(m.txn.ctx)
+
+	var last any
+	if opt.Checkpoints != nil {
+		checkpoint, err := loadCheckpoint(ctx, opt.Checkpoints, key)
+		if err != nil {
+			return false, err
+		}
+		if done, _ := checkpoint["done"].(bool); done {
+			return true, nil
+		}
+		last = checkpoint["last"]
+	}
+
+	conds := bson.A{}
+	if len(filter) > 0 {
+		conds = append(conds, filter)
+	}
+	if part.lo != nil {
+		conds = append(conds, bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: part.lo}}}})
+	}
+	if part.hi != nil {
+		conds = append(conds, bson.D{{Key: "_id", Value: bson.D{{Key: "$lt", Value: part.hi}}}})
+	}
+	if last != nil {
+		conds = append(conds, bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: last}}}})
+	}
+	scope := Map()
+	if len(conds) > 0 {
+		scope.Set("$and", conds)
+	}
+
+	batch := opt.BatchSize
+	if batch < 1 {
+		batch = defaultListLimit
+	}
+
+	var (
+		scanErr error
+		stop    bool
+		count   int
+	)
+	m.scan(scope, &IterOptions{BatchSize: batch, Projection: opt.Projection}, func(doc M, id any, err error) bool {
+		if err != nil {
+			scanErr = err
+			return false
+		}
+		ok, err := cb(doc)
+		if err != nil {
+			scanErr = err
+			return false
+		}
+		processed.Add(1)
+		last = id
+
+		if count++; count%batch == 0 {
+			if opt.Checkpoints != nil {
+				if scanErr = saveCheckpoint(ctx, opt.Checkpoints, key, bson.D{{Key: "last", Value: last}}); scanErr != nil {
+					return false
+				}
+			}
+			report(i, false)
+		}
+		stop = !ok
+		return ok
+	})
+	if scanErr != nil || stop {
+		if opt.Checkpoints != nil && last != nil {
+			if err := saveCheckpoint(ctx, opt.Checkpoints, key, bson.D{{Key: "last", Value: last}}); scanErr == nil {
+				scanErr = err
+			}
+		}
+		return false, scanErr
+	}
+
+	if opt.Checkpoints != nil {
+		if err := saveCheckpoint(ctx, opt.Checkpoints, key, bson.D{{Key: "done", Value: true}}); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// partitions returns the _id ranges of a scan, reusing the split points of a checkpoint.
+func (m *Model) partitions(filter M, opt *ParallelOptions) ([]partition, error) {
+	var bounds bson.A
+	if opt.Checkpoints != nil {
+		checkpoint, err := loadCheckpoint(m.txn.ctx, opt.Checkpoints, opt.Key)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := checkpoint["bounds"]; ok {
+			bounds, _ = v.(bson.A)
+			return toPartitions(bounds), nil
+		}
+	}
+
+	var err error
+	switch opt.Split {
+	case SplitSequentialID:
+		bounds, err = m.sequentialSplits(filter, opt.Partitions)
+	default:
+		bounds, err = m.sampleSplits(filter, opt.Partitions)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Checkpoints != nil {
+		if err := saveCheckpoint(m.txn.ctx, opt.Checkpoints, opt.Key, bson.D{{Key: "bounds", Value: bounds}}); err != nil {
+			return nil, err
+		}
+	}
+	return toPartitions(bounds), nil
+}
+
+// toPartitions turns n ordered split points into n+1 ranges.
+func toPartitions(bounds bson.A) []partition {
+	parts := make([]partition, 0, len(bounds)+1)
+	var lo any
+	for _, b := range bounds {
+		parts = append(parts, partition{lo: lo, hi: b})
+		lo = b
+	}
+	return append(parts, partition{lo: lo})
+}
+
+// sampleSplits picks n-1 split points from a $sample of the matching _id values.
+func (m *Model) sampleSplits(filter M, n int) (bson.A, error) {
+	match := any(bson.D{})
+	if len(filter) > 0 {
+		match = filter
+	}
+	pipeline := NewPipeline().
+		Match(match).
+		Stage("$sample", bson.D{{Key: "size", Value: n * samplesPerPartition}}).
+		Project(bson.D{{Key: "_id", Value: 1}})
+
+	var ids []any
+	err := Aggregate(m, pipeline, func(doc *bson.D) (bool, error) {
+		if id, ok := docGet(*doc, "_id"); ok {
+			ids = append(ids, id)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ids, func(i, j int) bool { return compareValues(ids[i], ids[j]) < 0 })
+
+	bounds := bson.A{}
+	for i := 1; i < n && len(ids) > 0; i++ {
+		b := ids[i*len(ids)/n]
+		if len(bounds) == 0 || compareValues(bounds[len(bounds)-1], b) < 0 {
+			bounds = append(bounds, b)
+		}
+	}
+	return bounds, nil
+}
+
+// sequentialSplits splits the time range between the smallest and largest SequentialID evenly.
+func (m *Model) sequentialSplits(filter M, n int) (bson.A, error) {
+	var query any
+	if len(filter) > 0 {
+		query = filter
+	}
+	lo, err := m.First(query, Map().Set("_id", 1), Map().Set("_id", 1))
+	if errors.Is(err, ErrRecordNotFound) {
+		return bson.A{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hi, err := m.First(query, Map().Set("_id", -1), Map().Set("_id", 1))
+	if err != nil {
+		return nil, err
+	}
+
+	from, ok1 := sequentialTime(lo["_id"])
+	to, ok2 := sequentialTime(hi["_id"])
+	if !ok1 || !ok2 {
+		return nil, errors.New("SplitSequentialID requires _id values generated by SequentialID")
+	}
+
+	bounds := bson.A{}
+	for i := 1; i < n; i++ {
+		b := sequentialPrefix(from + (to-from)*int64(i)/int64(n))
+		if len(bounds) == 0 || bounds[len(bounds)-1].(string) < b {
+			bounds = append(bounds, b)
+		}
+	}
+	return bounds, nil
+}
+
+// sequentialTime returns the microsecond timestamp of a SequentialID.
+func sequentialTime(id any) (int64, bool) {
+	s, ok := id.(string)
+	if !ok || len(s) <= 3 {
+		return 0, false
+	}
+	var sb strings.Builder
+	for _, r := range s[:len(s)-3] {
+		sb.WriteRune(r - 49)
+	}
+	t, err := strconv.ParseInt(sb.String(), 10, 64)
+	return t, err == nil
+}
+
+// sequentialPrefix encodes a microsecond timestamp like SequentialID, without the random suffix.
+// It sorts before every SequentialID of the same or a later microsecond.
+func sequentialPrefix(t int64) string {
+	var sb strings.Builder
+	for _, r := range strconv.FormatInt(t, 10) {
+		sb.WriteRune(r + 49)
+	}
+	return sb.String()
+}
+
+// loadCheckpoint returns the checkpoint saved for key, or nil.
+func loadCheckpoint(ctx context.Context, store TokenStore, key string) (M, error) {
+	token, err := store.LoadToken(ctx, key)
+	if err != nil || len(token) == 0 {
+		return nil, err
+	}
+	doc, err := toDocument(token)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := Map()
+	for _, e := range doc {
+		checkpoint[e.Key] = e.Value
+	}
+	return checkpoint, nil
+}
+
+// saveCheckpoint saves a checkpoint for key; an empty checkpoint resets it.
+func saveCheckpoint(ctx context.Context, store TokenStore, key string, checkpoint bson.D) error {
+	token, err := bson.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return store.SaveToken(ctx, key, token)
+}
+
+// resetCheckpoints clears the checkpoints of a complete scan, so the next scan starts over.
+func (m *Model) resetCheckpoints(ctx context.Context, opt *ParallelOptions, partitions int) error {
+	if opt.Checkpoints == nil {
+		return nil
+	}
+	for i := 0; i < partitions; i++ {
+		if err := saveCheckpoint(ctx, opt.Checkpoints, fmt.Sprintf("%s/%d", opt.Key, i), bson.D{}); err != nil {
+			return err
+		}
+	}
+	return saveCheckpoint(ctx, opt.Checkpoints, opt.Key, bson.D{})
+}
@@ -0,0 +1,158 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryParallelList(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	for i := 0; i < 200; i++ {
+		require.NoError(t, db.Set(&memUser{ID: fmt.Sprintf("id%03d", i), Name: fmt.Sprintf("n%03d", i), Age: int64(i % 2)}))
+	}
+	checkpoints := mongo.NewTokenStore(db, "scan_checkpoints")
+
+	scan := func(model *mongo.Model, filter mongo.M, cb func(m mongo.M) (bool, error), opts ...func(o *mongo.ParallelOptions)) (map[string]int, mongo.ParallelProgress, error) {
+		var mu sync.Mutex
+		seen := map[string]int{}
+		var last mongo.ParallelProgress
+		opts = append(opts, func(o *mongo.ParallelOptions) {
+			o.BatchSize = 10
+			o.Progress = func(p mongo.ParallelProgress) { last = p }
+		})
+		err := model.ParallelList(ctx, filter, 4, func(m mongo.M) (bool, error) {
+			ok, err := cb(m)
+			if err == nil {
+				mu.Lock()
+				seen[m["_id"].(string)]++
+				mu.Unlock()
+			}
+			return ok, err
+		}, opts...)
+		return seen, last, err
+	}
+	all := func(m mongo.M) (bool, error) { return true, nil }
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&memUser{})
+
+		seen, progress, err := scan(model, nil, all)
+		require.NoError(t, err)
+		require.Len(t, seen, 200)
+		require.Greater(t, progress.Partitions, 1)
+		require.Equal(t, progress.Partitions, progress.Completed)
+		require.Equal(t, int64(200), progress.Processed)
+
+		seen, _, err = scan(model, mongo.Map().Set("age", 1), all)
+		require.NoError(t, err)
+		require.Len(t, seen, 100)
+
+		// fewer partitions than workers are kept
+		seen, progress, err = scan(model, nil, all, func(o *mongo.ParallelOptions) { o.Partitions = 2 })
+		require.NoError(t, err)
+		require.Len(t, seen, 200)
+		require.Equal(t, 2, progress.Partitions)
+
+		// the first error cancels the scan, which resumes from the checkpoints
+		failing := func(m mongo.M) (bool, error) {
+			if m["_id"] == "id150" {
+				return false, errors.New("boom")
+			}
+			return true, nil
+		}
+		withCheckpoints := func(o *mongo.ParallelOptions) { o.Checkpoints = checkpoints }
+		first, _, err := scan(model, nil, failing, withCheckpoints)
+		require.EqualError(t, err, "boom")
+		require.Less(t, len(first), 200)
+
+		second, _, err := scan(model, nil, all, withCheckpoints)
+		require.NoError(t, err)
+		for id := range first {
+			require.NotContains(t, second, id)
+		}
+		require.Len(t, second, 200-len(first))
+
+		// checkpoints are reset after a complete scan
+		seen, _, err = scan(model, nil, all, withCheckpoints)
+		require.NoError(t, err)
+		require.Len(t, seen, 200)
+
+		// returning false stops the scan without error
+		var calls atomic.Int64
+		_, _, err = scan(model, nil, func(m mongo.M) (bool, error) {
+			return calls.Add(1) < 5, nil
+		})
+		require.NoError(t, err)
+		require.Less(t, calls.Load(), int64(200))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMemoryParallelListStopAndError(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Set(&memUser{ID: fmt.Sprintf("id%03d", i), Name: fmt.Sprintf("n%03d", i)}))
+	}
+
+	// an error after another partition stopped the scan is still returned
+	var calls atomic.Int64
+	started := make(chan struct{})
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		return txn.Model(&memUser{}).ParallelList(ctx, nil, 2, func(m mongo.M) (bool, error) {
+			if calls.Add(1) == 1 {
+				// stop once the other partition is in its callback
+				<-started
+				return false, nil
+			}
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			return false, errors.New("boom")
+		}, func(o *mongo.ParallelOptions) { o.Partitions = 2 })
+	})
+	require.EqualError(t, err, "boom")
+}
+
+func TestMemoryParallelListSequentialID(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	ids := map[string]bool{}
+	for len(ids) < 100 {
+		id := mongo.SequentialID()
+		if !ids[id] {
+			ids[id] = true
+			require.NoError(t, db.Set(&memUser{ID: id, Name: id}))
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		var mu sync.Mutex
+		seen := map[string]bool{}
+		partitions := 0
+		err := txn.Model(&memUser{}).ParallelList(ctx, nil, 3, func(m mongo.M) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[m["_id"].(string)] = true
+			return true, nil
+		}, func(o *mongo.ParallelOptions) {
+			o.Split = mongo.SplitSequentialID
+			o.Progress = func(p mongo.ParallelProgress) { partitions = p.Partitions }
+		})
+		require.NoError(t, err)
+		require.Equal(t, ids, seen)
+		require.Equal(t, 12, partitions)
+		return nil
+	})
+	require.NoError(t, err)
+}
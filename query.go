@@ -0,0 +1,231 @@
+// Package mongo provides a fluent builder for query filters, sort and projection specs.
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Query is an ordered MongoDB filter document built with Where, And, Or and Not.
+// It can be passed anywhere a filter is accepted, such as Model.First, Model.Count,
+// Model.Pagination and Model.UpdateMany. Use M to pass it to Model.List.
+//
+// Example:
+//
+//	q := mongo.Where("age").Gte(18).And(mongo.Where("status").In("a", "b"))
+//	record, err := txn.Model(&User{}).First(q, mongo.Desc("created_at"))
+type Query bson.D
+
+// Field builds conditions on a single document field.
+type Field struct {
+	name string
+}
+
+// Where starts a condition on the given field. Dotted paths are allowed.
+func Where(field string) Field {
+	return Field{name: field}
+}
+
+func (f Field) op(op string, v any) Query {
+	return Query{{Key: f.name, Value: bson.D{{Key: op, Value: v}}}}
+}
+
+// Eq matches documents where the field equals v.
+func (f Field) Eq(v any) Query {
+	return Query{{Key: f.name, Value: v}}
+}
+
+// Ne matches documents where the field does not equal v.
+func (f Field) Ne(v any) Query {
+	return f.op("$ne", v)
+}
+
+// Gt matches documents where the field is greater than v.
+func (f Field) Gt(v any) Query {
+	return f.op("$gt", v)
+}
+
+// Gte matches documents where the field is greater than or equal to v.
+func (f Field) Gte(v any) Query {
+	return f.op("$gte", v)
+}
+
+// Lt matches documents where the field is less than v.
+func (f Field) Lt(v any) Query {
+	return f.op("$lt", v)
+}
+
+// Lte matches documents where the field is less than or equal to v.
+func (f Field) Lte(v any) Query {
+	return f.op("$lte", v)
+}
+
+// In matches documents where the field equals any of the given values.
+func (f Field) In(values ...any) Query {
+	return f.op("$in", bson.A(values))
+}
+
+// Nin matches documents where the field equals none of the given values.
+func (f Field) Nin(values ...any) Query {
+	return f.op("$nin", bson.A(values))
+}
+
+// All matches arrays that contain all of the given values.
+func (f Field) All(values ...any) Query {
+	return f.op("$all", bson.A(values))
+}
+
+// Size matches arrays with exactly n elements.
+func (f Field) Size(n int) Query {
+	return f.op("$size", n)
+}
+
+// Exists matches documents that contain (or lack) the field.
+func (f Field) Exists(exists bool) Query {
+	return f.op("$exists", exists)
+}
+
+// Regex matches string fields against a regular expression with optional flags such as "i".
+func (f Field) Regex(pattern string, flags ...string) Query {
+	regex := primitive.Regex{Pattern: pattern}
+	if len(flags) > 0 {
+		regex.Options = flags[0]
+	}
+	return f.op("$regex", regex)
+}
+
+// ElemMatch matches arrays with at least one element satisfying the query.
+func (f Field) ElemMatch(q Query) Query {
+	return f.op("$elemMatch", bson.D(q))
+}
+
+// And combines the query with other queries; all of them must match.
+// Conditions on distinct fields are merged into one document, otherwise $and is used.
+func (q Query) And(others ...Query) Query {
+	return And(append([]Query{q}, others...)...)
+}
+
+// Or combines the query with other queries; at least one of them must match.
+func (q Query) Or(others ...Query) Query {
+	return Or(append([]Query{q}, others...)...)
+}
+
+// M converts the query to an M, as accepted by Model.List and Model.Next.
+func (q Query) M() M {
+	m := Map()
+	for _, e := range q {
+		m[e.Key] = e.Value
+	}
+	return m
+}
+
+// D returns the query as a bson.D.
+func (q Query) D() bson.D {
+	return bson.D(q)
+}
+
+// And returns a query matching documents that satisfy all the given queries.
+func And(queries ...Query) Query {
+	merged := Query{}
+	keys := make(map[string]struct{})
+	for _, q := range queries {
+		for _, e := range q {
+			if _, ok := keys[e.Key]; ok {
+				return logical("$and", queries)
+			}
+			keys[e.Key] = struct{}{}
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+// Or returns a query matching documents that satisfy at least one of the given queries.
+func Or(queries ...Query) Query {
+	return logical("$or", queries)
+}
+
+// Nor returns a query matching documents that satisfy none of the given queries.
+func Nor(queries ...Query) Query {
+	return logical("$nor", queries)
+}
+
+// Not returns a query matching documents that do not satisfy q.
+func Not(q Query) Query {
+	return Nor(q)
+}
+
+func logical(op string, queries []Query) Query {
+	arr := bson.A{}
+	for _, q := range queries {
+		if len(q) > 0 {
+			arr = append(arr, bson.D(q))
+		}
+	}
+	return Query{{Key: op, Value: arr}}
+}
+
+// SortSpec is an ordered sort specification.
+//
+// Example:
+//
+//	sort := mongo.Desc("created_at").Asc("name")
+type SortSpec bson.D
+
+// Asc returns a sort specification ordering the given fields ascending.
+func Asc(fields ...string) SortSpec {
+	return SortSpec{}.Asc(fields...)
+}
+
+// Desc returns a sort specification ordering the given fields descending.
+func Desc(fields ...string) SortSpec {
+	return SortSpec{}.Desc(fields...)
+}
+
+// Asc returns a copy of the sort specification with ascending fields appended.
+func (s SortSpec) Asc(fields ...string) SortSpec {
+	return SortSpec(appendFields(bson.D(s), fields, 1))
+}
+
+// Desc returns a copy of the sort specification with descending fields appended.
+func (s SortSpec) Desc(fields ...string) SortSpec {
+	return SortSpec(appendFields(bson.D(s), fields, -1))
+}
+
+// Projection is a field projection specification.
+//
+// Example:
+//
+//	user, err := txn.Model(&User{}).Get("user123", mongo.Include("name", "age"))
+type Projection bson.D
+
+// Include returns a projection that includes only the given fields.
+func Include(fields ...string) Projection {
+	return Projection{}.Include(fields...)
+}
+
+// Exclude returns a projection that excludes the given fields.
+func Exclude(fields ...string) Projection {
+	return Projection{}.Exclude(fields...)
+}
+
+// Include returns a copy of the projection with fields to be returned.
+func (p Projection) Include(fields ...string) Projection {
+	return Projection(appendFields(bson.D(p), fields, 1))
+}
+
+// Exclude returns a copy of the projection with fields to be omitted.
+func (p Projection) Exclude(fields ...string) Projection {
+	return Projection(appendFields(bson.D(p), fields, 0))
+}
+
+// appendFields returns a copy of d with the fields appended with value v, so specifications
+// derived from the same base don't share a backing array.
+func appendFields(d bson.D, fields []string, v int) bson.D {
+	out := make(bson.D, len(d), len(d)+len(fields))
+	copy(out, d)
+	for _, f := range fields {
+		out = append(out, bson.E{Key: f, Value: v})
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package mongo_test
+
+import (
+	"testing"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    mongo.Query
+		expected bson.D
+	}{
+		{
+			name:     "eq",
+			query:    mongo.Where("name").Eq("John"),
+			expected: bson.D{{Key: "name", Value: "John"}},
+		},
+		{
+			name:  "and distinct fields are merged",
+			query: mongo.Where("age").Gte(18).And(mongo.Where("status").In("a", "b")),
+			expected: bson.D{
+				{Key: "age", Value: bson.D{{Key: "$gte", Value: 18}}},
+				{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{"a", "b"}}}},
+			},
+		},
+		{
+			name:  "and same field uses $and",
+			query: mongo.Where("age").Gte(18).And(mongo.Where("age").Lt(65)),
+			expected: bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: 18}}}},
+				bson.D{{Key: "age", Value: bson.D{{Key: "$lt", Value: 65}}}},
+			}}},
+		},
+		{
+			name:  "or",
+			query: mongo.Or(mongo.Where("a").Exists(true), mongo.Where("b").Ne(nil)),
+			expected: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "a", Value: bson.D{{Key: "$exists", Value: true}}}},
+				bson.D{{Key: "b", Value: bson.D{{Key: "$ne", Value: nil}}}},
+			}}},
+		},
+		{
+			name:  "not",
+			query: mongo.Not(mongo.Where("a").Eq(1)),
+			expected: bson.D{{Key: "$nor", Value: bson.A{
+				bson.D{{Key: "a", Value: 1}},
+			}}},
+		},
+		{
+			name:     "elem match",
+			query:    mongo.Where("tags").ElemMatch(mongo.Where("k").Eq("v")),
+			expected: bson.D{{Key: "tags", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "k", Value: "v"}}}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.query.D())
+
+			// the builder types must encode as documents for the driver
+			raw, err := bson.Marshal(tt.query)
+			require.NoError(t, err)
+			expected, err := bson.Marshal(tt.expected)
+			require.NoError(t, err)
+			require.Equal(t, bson.Raw(expected).String(), bson.Raw(raw).String())
+		})
+	}
+
+	q := mongo.Where("name").Regex("^jo", "i").M()
+	_, ok := q.Get("name")
+	require.True(t, ok)
+
+	raw, err := bson.Marshal(mongo.Desc("created_at").Asc("name"))
+	require.NoError(t, err)
+	require.Equal(t, `{"created_at": {"$numberInt":"-1"},"name": {"$numberInt":"1"}}`, bson.Raw(raw).String())
+
+	raw, err = bson.Marshal(mongo.Include("name").Exclude("_id"))
+	require.NoError(t, err)
+	require.Equal(t, `{"name": {"$numberInt":"1"},"_id": {"$numberInt":"0"}}`, bson.Raw(raw).String())
+}
+
+func TestSortAndProjectionCopy(t *testing.T) {
+	base := mongo.Desc("a", "b", "c")
+	x := base.Asc("d")
+	y := base.Asc("e")
+	require.Equal(t, mongo.SortSpec{{Key: "a", Value: -1}, {Key: "b", Value: -1}, {Key: "c", Value: -1}}, base)
+	require.Equal(t, bson.E{Key: "d", Value: 1}, x[3])
+	require.Equal(t, bson.E{Key: "e", Value: 1}, y[3])
+
+	fields := mongo.Include("a", "b", "c")
+	p := fields.Include("d")
+	q := fields.Exclude("_id")
+	require.Equal(t, bson.E{Key: "d", Value: 1}, p[3])
+	require.Equal(t, bson.E{Key: "_id", Value: 0}, q[3])
+}
@@ -0,0 +1,390 @@
+// Package mongo provides the cached description of the special fields of a model type.
+package mongo
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// schema lists the fields of a model type that Model maintains itself.
+// A nil field means the model doesn't use the feature.
+type schema struct {
+	// typ is the struct type of the model, nil for maps and other types.
+	typ reflect.Type
+
+	// afterFind and beforeDelete report whether *typ implements AfterFinder and BeforeDeleter.
+	afterFind    bool
+	beforeDelete bool
+
+	createdAt *schemaField
+	updatedAt *schemaField
+	version   *schemaField
+	deletedAt *schemaField
+
+	// fields lists the top-level BSON keys of the struct, including those of inlined structs.
+	fields []string
+}
+
+// schemaField is a tagged struct field.
+type schemaField struct {
+	// name is the BSON name of the field.
+	name string
+
+	// index is the reflect index path of the field.
+	index []int
+}
+
+var schemas sync.Map // reflect.Type -> *schema
+
+// schemaOf returns the cached schema of the model's struct type. Maps and other types get an empty schema.
+func schemaOf(model any) *schema {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return &schema{}
+	}
+
+	if s, ok := schemas.Load(t); ok {
+		return s.(*schema)
+	}
+	s := &schema{
+		typ:          t,
+		afterFind:    reflect.PointerTo(t).Implements(afterFinderType),
+		beforeDelete: reflect.PointerTo(t).Implements(beforeDeleterType),
+	}
+	s.parse(t, nil)
+	actual, _ := schemas.LoadOrStore(t, s)
+	return actual.(*schema)
+}
+
+// parse collects the tagged fields of t, descending into inlined structs.
+func (s *schema) parse(t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := bsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		path := append(append([]int(nil), index...), i)
+
+		if inline {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.parse(ft, path)
+			}
+			continue
+		}
+		s.fields = append(s.fields, name)
+
+		tag := field.Tag.Get(TagName)
+		if tag == "" {
+			continue
+		}
+		info := ParseTag(tag)
+		f := &schemaField{name: name, index: path}
+		if info.CreatedAt {
+			s.createdAt = f
+		}
+		if info.UpdatedAt {
+			s.updatedAt = f
+		}
+		if info.Version {
+			s.version = f
+		}
+		if info.DeletedAt {
+			s.deletedAt = f
+		}
+	}
+}
+
+// bsonFieldName returns the BSON key of a struct field as the driver encodes it.
+func bsonFieldName(field reflect.StructField) (name string, inline bool) {
+	parts := strings.Split(field.Tag.Get("bson"), ",")
+	for _, opt := range parts[1:] {
+		if strings.TrimSpace(opt) == "inline" {
+			inline = true
+		}
+	}
+	name = strings.TrimSpace(parts[0])
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, inline
+}
+
+// hasTimestamps reports whether the model has created_at or updated_at fields.
+func (s *schema) hasTimestamps() bool {
+	return s.createdAt != nil || s.updatedAt != nil
+}
+
+// now returns the current time at the millisecond precision stored by MongoDB.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// fieldValue returns the addressable struct field of record, or false if record isn't a
+// pointer to the struct or the field lies behind a nil embedded pointer.
+func (f *schemaField) fieldValue(record any) (reflect.Value, bool) {
+	v := reflect.ValueOf(record)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return reflect.Value{}, false
+	}
+	fv, err := v.Elem().FieldByIndexErr(f.index)
+	if err != nil || !fv.CanSet() {
+		return reflect.Value{}, false
+	}
+	return fv, true
+}
+
+// setTime stores t in a time.Time or *time.Time field of record.
+// With onlyZero, a field already holding a time is left unchanged.
+func (f *schemaField) setTime(record any, t time.Time, onlyZero bool) {
+	fv, ok := f.fieldValue(record)
+	if !ok {
+		return
+	}
+	switch fv.Interface().(type) {
+	case time.Time:
+		if !onlyZero || fv.Interface().(time.Time).IsZero() {
+			fv.Set(reflect.ValueOf(t))
+		}
+	case *time.Time:
+		if !onlyZero || fv.IsNil() || fv.Interface().(*time.Time).IsZero() {
+			fv.Set(reflect.ValueOf(&t))
+		}
+	}
+}
+
+// setStoredTime stores a time read from a document in a time.Time or *time.Time field of record.
+func (f *schemaField) setStoredTime(record, v any) {
+	switch t := v.(type) {
+	case time.Time:
+		f.setTime(record, t.UTC(), false)
+	case primitive.DateTime:
+		f.setTime(record, t.Time().UTC(), false)
+	}
+}
+
+// isZeroTime reports whether a document value is missing, null or the zero time.
+func isZeroTime(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case time.Time:
+		return t.IsZero()
+	case *time.Time:
+		return t == nil || t.IsZero()
+	case primitive.DateTime:
+		return t.Time().IsZero()
+	}
+	return false
+}
+
+// setInt stores n in an integer field of record.
+func (f *schemaField) setInt(record any, n int64) {
+	fv, ok := f.fieldValue(record)
+	if !ok {
+		return
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fv.SetUint(uint64(n))
+	}
+}
+
+// intValue returns the value of an integer field.
+func intValue(fv reflect.Value) (int64, bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(fv.Uint()), true
+	}
+	return 0, false
+}
+
+// write is a prepared single-document write of Model.Set or Model.Update.
+type write struct {
+	filter bson.D
+
+	// replacement is the document of a Set without created_at field, nil otherwise.
+	replacement any
+
+	// update is the update document when replacement is nil.
+	update bson.D
+
+	// versioned is true when the filter checks the version; version is the version after the write.
+	versioned bool
+	version   int64
+}
+
+// newWrite starts a write of record, selecting the document with filter or, if nil, by the record's ID.
+func newWrite(record, filter any) (*write, error) {
+	id := GetID(record)
+	if id == nil || id == "" {
+		return nil, ErrNoID
+	}
+	if filter == nil {
+		return &write{filter: bson.D{{Key: "_id", Value: id}}}, nil
+	}
+
+	doc, ok := filter.(bson.D)
+	if !ok {
+		var err error
+		if doc, err = toDocument(filter); err != nil {
+			return nil, err
+		}
+	}
+	// copy, so the version condition is never appended to the caller's filter
+	return &write{filter: append(bson.D(nil), doc...)}, nil
+}
+
+// versionFilter restricts filter to the given version. Version 0 also matches documents without version.
+func (m *Model) versionFilter(filter bson.D, version int64) bson.D {
+	name := m.schema.version.name
+	if version == 0 {
+		return append(filter, bson.E{Key: name, Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}})
+	}
+	return append(filter, bson.E{Key: name, Value: version})
+}
+
+// prepareSet fills the update time of record and returns the write of Model.Set, see newWrite for filter.
+// Without created_at and version fields the write replaces the document with the record itself.
+// With a version field the filter also matches the record's version and the version is increased.
+// With a created_at field it is an update setting every field of the record, unsetting the struct's
+// fields missing from it and writing the creation time only on insert; the record's creation time
+// is left for the caller to fill from the stored document.
+func (m *Model) prepareSet(record, filter any) (*write, error) {
+	w, err := newWrite(record, filter)
+	if err != nil {
+		return nil, err
+	}
+	if !m.schema.hasTimestamps() && m.schema.version == nil {
+		w.replacement = record
+		return w, nil
+	}
+
+	t := now()
+	if f := m.schema.updatedAt; f != nil {
+		f.setTime(record, t, false)
+	}
+
+	doc, err := toDocument(record)
+	if err != nil {
+		return nil, err
+	}
+	if f := m.schema.updatedAt; f != nil {
+		doc = docSet(doc, f.name, t)
+	}
+	if f := m.schema.version; f != nil {
+		v, _ := docGet(doc, f.name)
+		current, _ := toInt64(v)
+		w.filter = m.versionFilter(w.filter, current)
+		w.versioned = true
+		w.version = current + 1
+		doc = docSet(doc, f.name, w.version)
+	}
+	if m.schema.createdAt == nil {
+		w.replacement = doc
+		return w, nil
+	}
+
+	created, _ := docGet(doc, m.schema.createdAt.name)
+	if isZeroTime(created) {
+		created = t
+	}
+	set := bson.D{}
+	for _, e := range doc {
+		if e.Key != "_id" && e.Key != m.schema.createdAt.name {
+			set = append(set, e)
+		}
+	}
+	// like a replacement, remove the fields the record no longer has, e.g. cleared omitempty fields
+	unset := bson.D{}
+	for _, name := range m.schema.fields {
+		if _, ok := docGet(doc, name); !ok && name != "_id" && name != m.schema.createdAt.name {
+			unset = append(unset, bson.E{Key: name, Value: ""})
+		}
+	}
+	if len(set) > 0 {
+		w.update = append(w.update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		w.update = append(w.update, bson.E{Key: "$unset", Value: unset})
+	}
+	w.update = append(w.update, bson.E{Key: "$setOnInsert", Value: bson.D{{Key: m.schema.createdAt.name, Value: created}}})
+	return w, nil
+}
+
+// prepareUpdate returns the write of a partial update of record, see newWrite for filter.
+// The update time is added and the creation time is never overwritten. With a version field
+// the version of record is checked, if it has one, and increased.
+func (m *Model) prepareUpdate(record, filter any) (*write, error) {
+	w, err := newWrite(record, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := m.updateFields(record)
+	if err != nil {
+		return nil, err
+	}
+	w.update = bson.D{{Key: "$set", Value: set}}
+
+	if f := m.schema.version; f != nil {
+		v, ok := set[f.name]
+		current, _ := toInt64(v)
+		if fv, isStruct := f.fieldValue(record); isStruct {
+			current, ok = intValue(fv)
+		}
+		delete(set, f.name)
+		if ok {
+			w.filter = m.versionFilter(w.filter, current)
+			w.versioned = true
+			w.version = current + 1
+		}
+		w.update = append(w.update, bson.E{Key: "$inc", Value: bson.D{{Key: f.name, Value: 1}}})
+	}
+	return w, nil
+}
+
+// updateFields converts update to the fields of a $set, applying the timestamps.
+func (m *Model) updateFields(update any) (M, error) {
+	t := now()
+	if f := m.schema.updatedAt; f != nil {
+		f.setTime(update, t, false)
+	}
+
+	raw, err := bson.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+	set := Map()
+	if err := bson.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+
+	if f := m.schema.createdAt; f != nil {
+		delete(set, f.name)
+	}
+	if f := m.schema.updatedAt; f != nil {
+		set[f.name] = t
+	}
+	return set, nil
+}
@@ -0,0 +1,160 @@
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTimestamps(t *testing.T) {
+	type Post struct {
+		ID        string     `bson:"_id"`
+		Title     string     `bson:"title"`
+		CreatedAt time.Time  `bson:"created_at" db:"created_at"`
+		UpdatedAt *time.Time `bson:"updated_at,omitempty" db:"updated_at"`
+	}
+
+	db := mongo.NewMemoryDatabase("test")
+
+	post := &Post{ID: "1", Title: "hello"}
+	require.NoError(t, db.Set(post))
+	require.False(t, post.CreatedAt.IsZero())
+	require.NotNil(t, post.UpdatedAt)
+	created := post.CreatedAt
+
+	stored := &Post{}
+	require.NoError(t, db.Unmarshal("1", stored))
+	require.True(t, created.Equal(stored.CreatedAt))
+
+	// a second Set never overwrites the creation time and reports the stored one
+	time.Sleep(2 * time.Millisecond)
+	again := &Post{ID: "1", Title: "again"}
+	require.NoError(t, db.Set(again))
+	require.True(t, created.Equal(again.CreatedAt))
+	require.NoError(t, db.Unmarshal("1", stored))
+	require.Equal(t, "again", stored.Title)
+	require.True(t, created.Equal(stored.CreatedAt))
+	require.True(t, stored.UpdatedAt.After(created))
+
+	// Update and UpdateMany refresh updated_at and keep created_at
+	time.Sleep(2 * time.Millisecond)
+	last := *stored.UpdatedAt
+	_, err := db.Update(&Post{ID: "1", Title: "updated"})
+	require.NoError(t, err)
+	require.NoError(t, db.Unmarshal("1", stored))
+	require.True(t, created.Equal(stored.CreatedAt))
+	require.True(t, stored.UpdatedAt.After(last))
+
+	time.Sleep(2 * time.Millisecond)
+	last = *stored.UpdatedAt
+	err = db.Txn(context.Background(), func(txn *mongo.Txn) error {
+		_, err := txn.Model(&Post{}).UpdateMany(mongo.Map(), mongo.Map().Set("title", "many"))
+		if err != nil {
+			return err
+		}
+		_, err = txn.Model(&Post{}).BulkSet([]any{&Post{ID: "2", Title: "bulk"}})
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Unmarshal("1", stored))
+	require.Equal(t, "many", stored.Title)
+	require.True(t, stored.UpdatedAt.After(last))
+
+	require.NoError(t, db.Unmarshal("2", stored))
+	require.False(t, stored.CreatedAt.IsZero())
+	require.NotNil(t, stored.UpdatedAt)
+}
+
+func TestMemorySetRemovesClearedFields(t *testing.T) {
+	type Post struct {
+		ID        string    `bson:"_id"`
+		Tags      []string  `bson:"tags,omitempty"`
+		Note      string    `bson:"note,omitempty"`
+		CreatedAt time.Time `bson:"created_at" db:"created_at"`
+	}
+
+	db := mongo.NewMemoryDatabase("test")
+	require.NoError(t, db.Set(&Post{ID: "1", Tags: []string{"a"}, Note: "n"}))
+
+	// fields cleared in the record are removed, as by a replacement
+	require.NoError(t, db.Set(&Post{ID: "1", Note: "m"}))
+	doc, err := db.First(&Post{}, mongo.Map().Set("_id", "1"), nil)
+	require.NoError(t, err)
+	require.NotContains(t, doc, "tags")
+	require.Equal(t, "m", doc["note"])
+	require.Contains(t, doc, "created_at")
+}
+
+func TestMemoryVersion(t *testing.T) {
+	type Account struct {
+		ID      string `bson:"_id"`
+		Balance int64  `bson:"balance"`
+		Version int64  `bson:"version" db:"version"`
+	}
+
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	acc := &Account{ID: "1", Balance: 10}
+	require.NoError(t, db.Set(acc))
+	require.Equal(t, int64(1), acc.Version)
+
+	// two copies read at version 1
+	a, b := &Account{}, &Account{}
+	require.NoError(t, db.Unmarshal("1", a))
+	require.NoError(t, db.Unmarshal("1", b))
+
+	a.Balance = 20
+	require.NoError(t, db.Set(a))
+	require.Equal(t, int64(2), a.Version)
+
+	b.Balance = 30
+	require.ErrorIs(t, db.Set(b), mongo.ErrVersionConflict)
+	_, err := db.Update(b)
+	require.ErrorIs(t, err, mongo.ErrVersionConflict)
+	err = db.Txn(ctx, func(txn *mongo.Txn) error {
+		// the stale upsert tries to insert a second document with the same _id
+		_, err := txn.Model(&Account{}).Update(b, func(o *mongo.UpdateOptions) { o.Upsert = true })
+		return err
+	})
+	require.ErrorIs(t, err, mongo.ErrVersionConflict)
+
+	// Update increments the version atomically
+	a.Balance = 25
+	record, err := db.Update(a)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), a.Version)
+	require.EqualValues(t, 3, record["version"])
+
+	_, err = db.Update(&Account{ID: "missing"})
+	require.ErrorIs(t, err, mongo.ErrRecordNotFound)
+
+	// retry reloads and succeeds
+	attempts := 0
+	err = mongo.RetryOnConflict(func() error {
+		attempts++
+		return db.Txn(ctx, func(txn *mongo.Txn) error {
+			accounts := mongo.NewCollection[Account](txn)
+			acc, err := accounts.Get("1")
+			if err != nil {
+				return err
+			}
+			if attempts == 1 {
+				// a concurrent writer wins the first round
+				require.NoError(t, db.Set(&Account{ID: "1", Balance: 100, Version: acc.Version}))
+			}
+			acc.Balance++
+			return accounts.Set(acc)
+		})
+	}, 3)
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+
+	stored := &Account{}
+	require.NoError(t, db.Unmarshal("1", stored))
+	require.Equal(t, int64(101), stored.Balance)
+	require.Equal(t, int64(5), stored.Version)
+}
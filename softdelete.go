@@ -0,0 +1,124 @@
+// Package mongo provides soft deletion for models with a db:"deleted_at" field.
+package mongo
+
+import (
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// deleteScope selects the documents a Model with a deleted_at field reads.
+type deleteScope int
+
+const (
+	// scopeLive excludes soft-deleted documents (the default).
+	scopeLive deleteScope = iota
+
+	// scopeAll includes soft-deleted documents.
+	scopeAll
+
+	// scopeDeleted only includes soft-deleted documents.
+	scopeDeleted
+)
+
+// WithDeleted returns a copy of the model whose reads and updates include soft-deleted documents.
+func (m *Model) WithDeleted() *Model {
+	c := *m
+	c.scope = scopeAll
+	return &c
+}
+
+// OnlyDeleted returns a copy of the model whose reads and updates only see soft-deleted documents.
+func (m *Model) OnlyDeleted() *Model {
+	c := *m
+	c.scope = scopeDeleted
+	return &c
+}
+
+// softDeletes reports whether Del marks documents as deleted instead of removing them.
+func (m *Model) softDeletes() bool {
+	return m.schema.deletedAt != nil
+}
+
+// scopeFilter returns the filter selecting the documents of the model's scope, or nil for all documents.
+// A document is deleted when its deleted_at field holds a time; missing, null and zero times are live.
+func (m *Model) scopeFilter() bson.D {
+	if !m.softDeletes() || m.scope == scopeAll {
+		return nil
+	}
+	deleted := bson.D{{Key: "$gt", Value: time.Time{}}}
+	if m.scope == scopeDeleted {
+		return bson.D{{Key: m.schema.deletedAt.name, Value: deleted}}
+	}
+	return bson.D{{Key: m.schema.deletedAt.name, Value: bson.D{{Key: "$not", Value: deleted}}}}
+}
+
+// scoped restricts filter to the model's scope.
+func (m *Model) scoped(filter any) any {
+	scope := m.scopeFilter()
+	if scope == nil {
+		if filter == nil {
+			return bson.D{}
+		}
+		return filter
+	}
+	if isEmptyFilter(filter) {
+		return scope
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, scope}}}
+}
+
+// isEmptyFilter reports whether filter is nil or an empty map, document or slice.
+func isEmptyFilter(filter any) bool {
+	val := reflect.ValueOf(filter)
+	return val.Kind() == reflect.Invalid ||
+		((val.Kind() == reflect.Map ||
+			val.Kind() == reflect.Slice ||
+			val.Kind() == reflect.Array) &&
+			val.Len() < 1)
+}
+
+// softDelete sets the deleted_at field of the live document with the given filter.
+func (m *Model) softDelete(filter any) mongo.WriteModel {
+	live := *m
+	live.scope = scopeLive
+	return mongo.NewUpdateOneModel().
+		SetFilter(live.scoped(filter)).
+		SetUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: m.schema.deletedAt.name, Value: now()}}}})
+}
+
+// Restore clears the deletion time of a soft-deleted document.
+// Returns ErrRecordNotFound if there is no soft-deleted document with the ID.
+func (m *Model) Restore(id any) error {
+	if !m.softDeletes() {
+		return ErrNotSupported
+	}
+
+	op := &Operation{Name: "Restore", Filter: GetIDFilter(id)}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		deleted := *m
+		deleted.scope = scopeDeleted
+		res, err := m.coll.UpdateOne(m.txn.ctx, deleted.scoped(op.Filter),
+			bson.D{{Key: "$unset", Value: bson.D{{Key: m.schema.deletedAt.name, Value: ""}}}})
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 0 {
+			return nil, ErrRecordNotFound
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// Purge permanently removes a document by its ID, whether it is soft-deleted or not.
+func (m *Model) Purge(id any) error {
+	op := &Operation{Name: "Purge", Filter: GetIDFilter(id)}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		_, err := m.coll.DeleteOne(m.txn.ctx, op.Filter)
+		return nil, err
+	})
+	return err
+}
@@ -0,0 +1,112 @@
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySoftDelete(t *testing.T) {
+	type Invoice struct {
+		ID        string     `bson:"_id"`
+		Amount    int64      `bson:"amount"`
+		DeletedAt *time.Time `bson:"deleted_at,omitempty" db:"deleted_at"`
+	}
+
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	for i, id := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, db.Set(&Invoice{ID: id, Amount: int64(i + 1)}))
+	}
+
+	require.NoError(t, db.Delete(&Invoice{}, "2"))
+	require.NoError(t, db.Delete(&Invoice{}, "3"))
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&Invoice{})
+
+		_, err := model.Get("2")
+		require.ErrorIs(t, err, mongo.ErrRecordNotFound)
+		has, err := model.Has("2")
+		require.NoError(t, err)
+		require.False(t, has)
+
+		count, err := model.Count(nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), count)
+		count, err = model.Count(mongo.Map().Set("amount", mongo.Map().Set("$gte", 2)))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+
+		_, err = model.First(nil, mongo.Desc("amount"))
+		require.NoError(t, err)
+		total, list, err := model.Pagination(nil, nil, 1, 10)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), total)
+		require.Len(t, list, 2)
+
+		var ids []string
+		require.NoError(t, model.List(nil, func(m mongo.M) (bool, error) {
+			ids = append(ids, m["_id"].(string))
+			return true, nil
+		}))
+		require.Equal(t, []string{"1", "4"}, ids)
+
+		// scopes
+		count, err = model.WithDeleted().Count(nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), count)
+		list, err = model.OnlyDeleted().Find(nil, mongo.Asc("_id"))
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		require.NotNil(t, list[0]["deleted_at"])
+
+		// every update path skips soft-deleted documents unless the scope includes them
+		deleted := mongo.Map().Set("_id", "2")
+		_, err = model.Update(&Invoice{ID: "2", Amount: 20})
+		require.ErrorIs(t, err, mongo.ErrRecordNotFound)
+		modified, err := model.UpdateMany(deleted, mongo.Map().Set("amount", 20))
+		require.NoError(t, err)
+		require.Zero(t, modified)
+		modified, err = model.UpdateMany(deleted, mongo.NewUpdate().Set("amount", 20))
+		require.NoError(t, err)
+		require.Zero(t, modified)
+		updated, err := model.UpdateByID("2", mongo.NewUpdate().Set("amount", 20))
+		require.NoError(t, err)
+		require.Zero(t, updated.Matched)
+		require.NoError(t, model.Inc("2", mongo.Map().Set("amount", 10)))
+		bulk, err := model.Bulk().Update(&Invoice{ID: "2", Amount: 20}).Inc("2", mongo.Map().Set("amount", 1)).Do()
+		require.NoError(t, err)
+		require.Zero(t, bulk.Matched)
+		stored, err := model.WithDeleted().Get("2")
+		require.NoError(t, err)
+		require.EqualValues(t, 2, stored["amount"])
+
+		modified, err = model.WithDeleted().UpdateMany(deleted, mongo.Map().Set("amount", 20))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), modified)
+
+		// restore and purge
+		require.NoError(t, model.Restore("2"))
+		require.ErrorIs(t, model.Restore("2"), mongo.ErrRecordNotFound)
+		_, err = model.Get("2")
+		require.NoError(t, err)
+
+		require.NoError(t, model.Purge("3"))
+		count, err = model.WithDeleted().Count(nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), count)
+
+		res, err := model.BulkDelete([]any{"1"})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), res.Modified)
+		has, err = model.WithDeleted().Has("1")
+		require.NoError(t, err)
+		require.True(t, has)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,92 @@
+// Package mongo provides the pluggable storage backend used by Model.
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Store is the per-collection storage used by Model.
+// It is the subset of *mongo.Collection that Model relies on, so the driver collection
+// satisfies it directly; NewMemoryDatabase provides an in-memory implementation.
+type Store interface {
+	Name() string
+	FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult
+	Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error)
+	EstimatedDocumentCount(ctx context.Context, opts ...*options.EstimatedDocumentCountOptions) (int64, error)
+	ReplaceOne(ctx context.Context, filter, replacement any, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error)
+	UpdateOne(ctx context.Context, filter, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateByID(ctx context.Context, id, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	FindOneAndUpdate(ctx context.Context, filter, update any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
+	DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+	Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
+	Watch(ctx context.Context, pipeline any, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+}
+
+// Backend provides collections and transactions for a Database.
+type Backend interface {
+	// Collection returns the store for the named collection.
+	Collection(name string) Store
+
+	// WithTransaction runs fn as a multi-document transaction.
+	// A non-nil error from fn must roll back the writes made through ctx.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// Indexes returns the index manager of the named collection.
+	Indexes(collection string) IndexManager
+
+	// Watch opens a change stream on the whole database.
+	Watch(ctx context.Context, pipeline any, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+}
+
+// driverBackend is the Backend of a Database connected through the official driver.
+type driverBackend struct {
+	db *Database
+}
+
+func (b driverBackend) Collection(name string) Store {
+	return b.db.Database.Collection(name)
+}
+
+func (b driverBackend) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// read preference in a transaction must be primary
+	sesstionOptions := &options.SessionOptions{DefaultReadPreference: readpref.Primary()}
+	session, err := b.db.Client.StartSession(sesstionOptions)
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+func (b driverBackend) Indexes(collection string) IndexManager {
+	return driverIndexes{view: b.db.Database.Collection(collection).Indexes()}
+}
+
+func (b driverBackend) Watch(ctx context.Context, pipeline any, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+	return b.db.Database.Watch(ctx, pipeline, opts...)
+}
+
+// backend returns the configured backend, defaulting to the driver.
+func (d *Database) backend() Backend {
+	if d.storage != nil {
+		return d.storage
+	}
+	return driverBackend{db: d}
+}
+
+// NewDatabaseWithBackend creates a database served by the given backend instead of a MongoDB server.
+// The embedded driver client and database are nil, so only the wrapper API is available.
+func NewDatabaseWithBackend(backend Backend) *Database {
+	return &Database{storage: backend, cursorSecret: newCursorSecret()}
+}
@@ -3,6 +3,7 @@ package mongo
 
 import (
 	"context"
+	"errors"
 )
 
 // Txn represents a database transaction context.
@@ -17,3 +18,56 @@ type Txn struct {
 func (txn *Txn) Model(model any) *Model {
 	return NewModel(txn, model)
 }
+
+// TxnResult executes fn in a transaction like Database.Txn and returns its result.
+// Errors from fn as well as session, commit and abort errors are returned; on error
+// the zero value of T is returned.
+//
+// Example:
+//
+//	user, err := mongo.TxnResult(ctx, db, func(txn *mongo.Txn) (mongo.M, error) {
+//	    return txn.Model(&User{}).Get("user123")
+//	}, true)
+func TxnResult[T any](ctx context.Context, db *Database, fn func(txn *Txn) (T, error), multiDoc ...bool) (T, error) {
+	var result T
+	err := db.Txn(ctx, func(txn *Txn) error {
+		var err error
+		result, err = fn(txn)
+		return err
+	}, multiDoc...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// RetryOnConflict runs fn until it returns something other than ErrVersionConflict,
+// at most attempts times, and returns its last error. fn should reload the record each time.
+//
+// Example:
+//
+//	err := mongo.RetryOnConflict(func() error {
+//	    return db.Txn(ctx, func(txn *mongo.Txn) error {
+//	        users := mongo.NewCollection[User](txn)
+//	        user, err := users.Get("user123")
+//	        if err != nil {
+//	            return err
+//	        }
+//	        user.Balance += 10
+//	        return users.Set(user)
+//	    })
+//	}, 3)
+func RetryOnConflict(fn func() error, attempts int) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		err = fn()
+		if !errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+// commitFailingBackend runs transactions on the memory backend and then fails their commit.
+type commitFailingBackend struct {
+	*mongo.MemoryBackend
+}
+
+func (b commitFailingBackend) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := b.MemoryBackend.WithTransaction(ctx, fn); err != nil {
+		return err
+	}
+	return errors.New("commit failed")
+}
+
+func TestTxnResultErrors(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	// an error from fn on the multi-document path reaches the caller and rolls back
+	boom := errors.New("boom")
+	res, err := mongo.TxnResult(ctx, db, func(txn *mongo.Txn) (mongo.M, error) {
+		if err := txn.Model(&memUser{}).Set(&memUser{ID: "1", Name: "a"}); err != nil {
+			return nil, err
+		}
+		return mongo.Map().Set("_id", "1"), boom
+	}, true)
+	require.ErrorIs(t, err, boom)
+	require.Nil(t, res)
+	has, err := db.Has(&memUser{}, "1")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// errors of the helpers built on TxnResult are returned too
+	_, err = db.Update(&memUser{ID: "missing", Name: "x"})
+	require.ErrorIs(t, err, mongo.ErrRecordNotFound)
+
+	// a commit error is returned with the zero value even though fn succeeded
+	failing := mongo.NewDatabaseWithBackend(commitFailingBackend{mongo.NewMemoryBackend("test")})
+	count, err := mongo.TxnResult(ctx, failing, func(txn *mongo.Txn) (int64, error) {
+		return 42, nil
+	}, true)
+	require.EqualError(t, err, "commit failed")
+	require.Zero(t, count)
+}
@@ -0,0 +1,247 @@
+// Package mongo provides an update builder for operator updates.
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Update is an update document built from update operators.
+//
+// Example:
+//
+//	u := mongo.NewUpdate().Set("name", "Bob").Inc("visits", 1).AddToSet("tags", "vip").Unset("token")
+//	res, err := txn.Model(&User{}).UpdateByID("user123", u)
+type Update bson.D
+
+// NewUpdate returns an empty update.
+func NewUpdate() Update {
+	return Update{}
+}
+
+// op adds a field to the document of the given operator.
+func (u Update) op(op, field string, v any) Update {
+	for i, e := range u {
+		if e.Key != op {
+			continue
+		}
+		if fields, ok := operatorFields(e.Value); ok {
+			out := append(Update(nil), u...)
+			out[i].Value = append(append(bson.D(nil), fields...), bson.E{Key: field, Value: v})
+			return out
+		}
+	}
+	// copy, so updates built from the same base never share their backing array
+	return append(append(Update(nil), u...), bson.E{Key: op, Value: bson.D{{Key: field, Value: v}}})
+}
+
+// has reports whether the update modifies field with any operator.
+func (u Update) has(field string) bool {
+	for _, e := range u {
+		fields, _ := operatorFields(e.Value)
+		if _, ok := docGet(fields, field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// operatorFields returns the fields of an operator, also when a hand-built Update holds them as a map.
+func operatorFields(v any) (bson.D, bool) {
+	if fields, ok := v.(bson.D); ok {
+		return fields, true
+	}
+	fields, err := toDocument(v)
+	return fields, err == nil
+}
+
+// Set sets the field to v ($set).
+func (u Update) Set(field string, v any) Update {
+	return u.op("$set", field, v)
+}
+
+// SetOnInsert sets the field to v only when an upsert inserts the document ($setOnInsert).
+func (u Update) SetOnInsert(field string, v any) Update {
+	return u.op("$setOnInsert", field, v)
+}
+
+// Unset removes the fields ($unset).
+func (u Update) Unset(fields ...string) Update {
+	for _, f := range fields {
+		u = u.op("$unset", f, "")
+	}
+	return u
+}
+
+// Inc increments the field by n, which can be negative ($inc).
+func (u Update) Inc(field string, n any) Update {
+	return u.op("$inc", field, n)
+}
+
+// Push appends the values to the array field ($push).
+func (u Update) Push(field string, values ...any) Update {
+	return u.op("$push", field, each(values))
+}
+
+// AddToSet appends the values that are not already in the array field ($addToSet).
+func (u Update) AddToSet(field string, values ...any) Update {
+	return u.op("$addToSet", field, each(values))
+}
+
+// Pull removes the elements of the array field matching cond, which is a value
+// or a condition such as mongo.Where("score").Lt(5) ($pull).
+func (u Update) Pull(field string, cond any) Update {
+	if q, ok := cond.(Query); ok {
+		cond = bson.D(q)
+	}
+	return u.op("$pull", field, cond)
+}
+
+// Min sets the field to v if v is less than its value ($min).
+func (u Update) Min(field string, v any) Update {
+	return u.op("$min", field, v)
+}
+
+// Max sets the field to v if v is greater than its value ($max).
+func (u Update) Max(field string, v any) Update {
+	return u.op("$max", field, v)
+}
+
+// CurrentDate sets the fields to the current date on the server ($currentDate).
+func (u Update) CurrentDate(fields ...string) Update {
+	for _, f := range fields {
+		u = u.op("$currentDate", f, true)
+	}
+	return u
+}
+
+// Rename renames the field from to the field to ($rename).
+func (u Update) Rename(from, to string) Update {
+	return u.op("$rename", from, to)
+}
+
+// D returns the update as a bson.D.
+func (u Update) D() bson.D {
+	return bson.D(u)
+}
+
+// each wraps several values in {$each: [...]}.
+func each(values []any) any {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return bson.D{{Key: "$each", Value: bson.A(values)}}
+}
+
+// UpdateOptions configures Update, UpdateAndGet, UpdateByID and UpdateWhere.
+type UpdateOptions struct {
+	// Upsert inserts a document built from the filter and the update when none matches.
+	Upsert bool
+
+	// ReturnBefore makes Update and UpdateAndGet return the document as it was before the update
+	// instead of after it.
+	ReturnBefore bool
+}
+
+// UpdateResult reports the outcome of UpdateByID and UpdateWhere.
+type UpdateResult struct {
+	// Matched is the number of documents matching the filter.
+	Matched int64
+	// Modified is the number of documents actually changed.
+	Modified int64
+	// Upserted is 1 when an upsert inserted a document.
+	Upserted int64
+	// UpsertedID is the _id of the inserted document.
+	UpsertedID any
+}
+
+// UpdateAndGet updates a record like Model.Update and returns the stored document decoded as *T,
+// or nil when Model.Update returns no document.
+//
+// Example:
+//
+//	user, err := mongo.UpdateAndGet[User](txn.Model(&User{}), mongo.Map().Set("_id", "user123").Set("address.city", "Paris"))
+func UpdateAndGet[T any](m *Model, update any, opts ...func(o *UpdateOptions)) (*T, error) {
+	doc, err := m.Update(update, opts...)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return Decode[T](doc)
+}
+
+// UpdateByID applies the update operators to the document with the given ID.
+// Fields tagged `db:"updated_at"` are set to the current time unless the update sets them,
+// `db:"created_at"` fields are set when an upsert inserts the document, and a `db:"version"`
+// field is increased.
+//
+// Example:
+//
+//	res, err := txn.Model(&User{}).UpdateByID("user123", mongo.NewUpdate().Push("tags", "a", "b"))
+func (m *Model) UpdateByID(id any, u Update, opts ...func(o *UpdateOptions)) (*UpdateResult, error) {
+	return m.updateWith("UpdateByID", GetIDFilter(id), u, false, opts)
+}
+
+// UpdateWhere applies the update operators to every document matching the filter.
+// Timestamps and versions are maintained like UpdateByID.
+//
+// Example:
+//
+//	res, err := txn.Model(&User{}).UpdateWhere(mongo.Where("age").Lt(18), mongo.NewUpdate().Set("minor", true))
+func (m *Model) UpdateWhere(filter any, u Update, opts ...func(o *UpdateOptions)) (*UpdateResult, error) {
+	return m.updateWith("UpdateWhere", filter, u, true, opts)
+}
+
+func (m *Model) updateWith(name string, filter any, u Update, many bool, opts []func(o *UpdateOptions)) (*UpdateResult, error) {
+	opt := &UpdateOptions{}
+	for _, v := range opts {
+		v(opt)
+	}
+
+	op := &Operation{Name: name, Filter: filter, Update: u, Options: Map().Set("upsert", opt.Upsert)}
+	return run(m, op, func(m *Model, op *Operation) (*UpdateResult, error) {
+		update, _ := op.Update.(Update)
+		return m.updateOperators(op.Filter, update, many, opt.Upsert)
+	})
+}
+
+// updateOperators applies an operator update to the first or every live document matching filter.
+func (m *Model) updateOperators(filter any, u Update, many, upsert bool) (*UpdateResult, error) {
+	update := bson.D(m.schemaUpdate(u))
+	updateOpt := options.Update().SetUpsert(upsert)
+
+	var res *mongo.UpdateResult
+	var err error
+	if many {
+		res, err = m.coll.UpdateMany(m.txn.ctx, m.scoped(filter), update, updateOpt)
+	} else {
+		res, err = m.coll.UpdateOne(m.txn.ctx, m.scoped(filter), update, updateOpt)
+	}
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return nil, ErrDuplicateKey
+		}
+		return nil, err
+	}
+	return &UpdateResult{
+		Matched:    res.MatchedCount,
+		Modified:   res.ModifiedCount,
+		Upserted:   res.UpsertedCount,
+		UpsertedID: res.UpsertedID,
+	}, nil
+}
+
+// schemaUpdate adds the timestamp and version fields of the model to an update.
+func (m *Model) schemaUpdate(u Update) Update {
+	t := now()
+	if f := m.schema.updatedAt; f != nil && !u.has(f.name) {
+		u = u.Set(f.name, t)
+	}
+	if f := m.schema.createdAt; f != nil && !u.has(f.name) {
+		u = u.SetOnInsert(f.name, t)
+	}
+	if f := m.schema.version; f != nil && !u.has(f.name) {
+		u = u.Inc(f.name, 1)
+	}
+	return u
+}
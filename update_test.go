@@ -0,0 +1,233 @@
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liran/mongo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMemoryUpdateOperators(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	type item struct {
+		ID        string    `bson:"_id"`
+		Name      string    `bson:"name"`
+		Alias     string    `bson:"alias,omitempty"`
+		Tags      []string  `bson:"tags"`
+		Scores    []int64   `bson:"scores"`
+		Low       int64     `bson:"low"`
+		High      int64     `bson:"high"`
+		Token     string    `bson:"token,omitempty"`
+		SeenAt    time.Time `bson:"seen_at,omitempty"`
+		Version   int64     `bson:"version" db:"version"`
+		UpdatedAt time.Time `bson:"updated_at" db:"updated_at"`
+	}
+
+	require.NoError(t, db.Set(&item{ID: "1", Name: "a", Tags: []string{"x"}, Scores: []int64{1, 7, 3, 9}, Low: 5, High: 5, Token: "t"}))
+	require.NoError(t, db.Set(&item{ID: "2", Name: "b", Tags: []string{}, Scores: []int64{}, Low: 5, High: 5}))
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&item{})
+
+		res, err := model.UpdateByID("1", mongo.NewUpdate().
+			Unset("token").Push("tags", "y", "z").AddToSet("tags", "x", "w").
+			Pull("scores", bson.D{{Key: "$gte", Value: 7}}).Min("low", 2).Max("high", 1).
+			CurrentDate("seen_at").Rename("name", "alias"))
+		require.NoError(t, err)
+		require.Equal(t, &mongo.UpdateResult{Matched: 1, Modified: 1}, res)
+
+		got, err := mongo.Decode[item](mustGet(t, model, "1"))
+		require.NoError(t, err)
+		require.Equal(t, "", got.Name)
+		require.Equal(t, "a", got.Alias)
+		require.Equal(t, "", got.Token)
+		require.Equal(t, []string{"x", "y", "z", "w"}, got.Tags)
+		require.Equal(t, []int64{1, 3}, got.Scores)
+		require.Equal(t, int64(2), got.Low)
+		require.Equal(t, int64(5), got.High)
+		require.WithinDuration(t, time.Now(), got.SeenAt, time.Minute)
+		require.WithinDuration(t, time.Now(), got.UpdatedAt, time.Minute)
+		require.Equal(t, int64(2), got.Version)
+
+		res, err = model.UpdateWhere(nil, mongo.NewUpdate().Inc("high", 1))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), res.Matched)
+		require.Equal(t, int64(2), res.Modified)
+
+		count, err := model.UpdateMany(mongo.Where("_id").Eq("2"), mongo.NewUpdate().Push("tags", "n"))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+
+		res, err = model.UpdateByID("missing", mongo.NewUpdate().Set("name", "m"))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), res.Matched)
+
+		res, err = model.UpdateByID("3", mongo.NewUpdate().Set("name", "c"), func(o *mongo.UpdateOptions) {
+			o.Upsert = true
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), res.Upserted)
+		require.Equal(t, "3", res.UpsertedID)
+		got, err = mongo.Decode[item](mustGet(t, model, "3"))
+		require.NoError(t, err)
+		require.Equal(t, "c", got.Name)
+		require.Equal(t, int64(1), got.Version)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func mustGet(t *testing.T, model *mongo.Model, id any) mongo.M {
+	doc, err := model.Get(id)
+	require.NoError(t, err)
+	return doc
+}
+
+func TestUpdateBuilder(t *testing.T) {
+	base := mongo.NewUpdate().Set("name", "Bob")
+	u := base.Inc("visits", 1).Set("age", 30).Unset("token", "code").
+		Push("tags", "a").AddToSet("roles", "x", "y").Pull("scores", mongo.Where("v").Lt(5)).
+		Min("low", 1).Max("high", 9).CurrentDate("seen_at").Rename("nick", "alias")
+
+	require.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "Bob"}, {Key: "age", Value: 30}}},
+		{Key: "$inc", Value: bson.D{{Key: "visits", Value: 1}}},
+		{Key: "$unset", Value: bson.D{{Key: "token", Value: ""}, {Key: "code", Value: ""}}},
+		{Key: "$push", Value: bson.D{{Key: "tags", Value: "a"}}},
+		{Key: "$addToSet", Value: bson.D{{Key: "roles", Value: bson.D{{Key: "$each", Value: bson.A{"x", "y"}}}}}},
+		{Key: "$pull", Value: bson.D{{Key: "scores", Value: bson.D{{Key: "v", Value: bson.D{{Key: "$lt", Value: 5}}}}}}},
+		{Key: "$min", Value: bson.D{{Key: "low", Value: 1}}},
+		{Key: "$max", Value: bson.D{{Key: "high", Value: 9}}},
+		{Key: "$currentDate", Value: bson.D{{Key: "seen_at", Value: true}}},
+		{Key: "$rename", Value: bson.D{{Key: "nick", Value: "alias"}}},
+	}, u.D())
+
+	// builders are values, extending one leaves it unchanged
+	require.Equal(t, bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "Bob"}}}}, base.D())
+
+	// updates extended from a base with spare capacity don't overwrite each other
+	spare := mongo.NewUpdate().Set("a", 1).Inc("b", 1).Push("c", 1)
+	require.Greater(t, cap(spare), len(spare))
+	u1 := spare.Unset("x")
+	u2 := spare.Max("y", 5)
+	require.Equal(t, bson.E{Key: "$unset", Value: bson.D{{Key: "x", Value: ""}}}, u1[3])
+	require.Equal(t, bson.E{Key: "$max", Value: bson.D{{Key: "y", Value: 5}}}, u2[3])
+	require.Len(t, spare, 3)
+
+	// hand-built updates may hold the fields of an operator as a map
+	hand := mongo.Update{{Key: "$set", Value: bson.M{"name": "Bob"}}}
+	require.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "Bob"}, {Key: "age", Value: 30}}},
+	}, hand.Set("age", 30).D())
+}
+
+func TestMemoryUpdateHandBuilt(t *testing.T) {
+	type stamped struct {
+		ID        string    `bson:"_id"`
+		Name      string    `bson:"name"`
+		UpdatedAt time.Time `bson:"updated_at" db:"updated_at"`
+	}
+
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+	require.NoError(t, db.Set(&stamped{ID: "1", Name: "a"}))
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&stamped{})
+		at := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+		res, err := model.UpdateByID("1", mongo.Update{{Key: "$set", Value: bson.M{"name": "b", "updated_at": at}}})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), res.Modified)
+
+		got, err := mongo.Decode[stamped](mustGet(t, model, "1"))
+		require.NoError(t, err)
+		require.Equal(t, "b", got.Name)
+		require.True(t, at.Equal(got.UpdatedAt))
+
+		// the timestamps are added to a copy of the caller's update
+		u := mongo.NewUpdate().Inc("n", 1).Push("tags", "t").Unset("x")
+		require.Greater(t, cap(u), len(u))
+		want := append(mongo.Update(nil), u...)
+		_, err = model.UpdateByID("1", u)
+		require.NoError(t, err)
+		require.Equal(t, want, u)
+		require.Zero(t, u[:len(u)+1][len(u)])
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMemoryUpdateReturnDocument(t *testing.T) {
+	ctx := context.Background()
+	db := mongo.NewMemoryDatabase("test")
+
+	type address struct {
+		City string `bson:"city"`
+		Zip  string `bson:"zip"`
+	}
+	type customer struct {
+		ID      string  `bson:"_id"`
+		Name    string  `bson:"name"`
+		Address address `bson:"address"`
+		Version int64   `bson:"version" db:"version"`
+	}
+	require.NoError(t, db.Set(&customer{ID: "1", Name: "a", Address: address{City: "Rome", Zip: "00100"}}))
+
+	err := db.Txn(ctx, func(txn *mongo.Txn) error {
+		model := txn.Model(&customer{})
+
+		doc, err := model.Update(mongo.Map().Set("_id", "1").Set("address.city", "Paris"))
+		require.NoError(t, err)
+		require.Equal(t, mongo.M{"city": "Paris", "zip": "00100"}, doc["address"])
+		require.Nil(t, doc["address.city"])
+		require.EqualValues(t, 2, doc["version"])
+
+		doc, err = model.Update(mongo.Map().Set("_id", "1").Set("name", "b"), func(o *mongo.UpdateOptions) {
+			o.ReturnBefore = true
+		})
+		require.NoError(t, err)
+		require.Equal(t, "a", doc["name"])
+		require.EqualValues(t, 2, doc["version"])
+
+		record := &customer{ID: "1", Name: "c", Address: address{City: "Oslo"}, Version: 3}
+		got, err := mongo.UpdateAndGet[customer](model, record)
+		require.NoError(t, err)
+		require.Equal(t, &customer{ID: "1", Name: "c", Address: address{City: "Oslo"}, Version: 4}, got)
+		require.Equal(t, int64(4), record.Version)
+
+		got, err = mongo.NewCollection[customer](txn).Update(mongo.Map().Set("_id", "1").Set("address.zip", "0150"))
+		require.NoError(t, err)
+		require.Equal(t, address{City: "Oslo", Zip: "0150"}, got.Address)
+
+		// an upsert that inserts has no document before the update
+		upsertBefore := func(o *mongo.UpdateOptions) {
+			o.Upsert = true
+			o.ReturnBefore = true
+		}
+		inserted := &customer{ID: "2", Name: "new"}
+		doc, err = model.Update(inserted, upsertBefore)
+		require.NoError(t, err)
+		require.Nil(t, doc)
+		require.Equal(t, int64(1), inserted.Version)
+		stored, err := mongo.Decode[customer](mustGet(t, model, "2"))
+		require.NoError(t, err)
+		require.Equal(t, "new", stored.Name)
+		require.Equal(t, int64(1), stored.Version)
+
+		got, err = mongo.UpdateAndGet[customer](model, &customer{ID: "3", Name: "typed"}, upsertBefore)
+		require.NoError(t, err)
+		require.Nil(t, got)
+
+		// an existing document is returned as it was
+		doc, err = model.Update(&customer{ID: "2", Name: "newer", Version: 1}, upsertBefore)
+		require.NoError(t, err)
+		require.Equal(t, "new", doc["name"])
+		return nil
+	})
+	require.NoError(t, err)
+}
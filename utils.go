@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -161,11 +163,128 @@ func GetID(model any) any {
 	return nil
 }
 
+// TextIndexName is the index shared by text fields whose tag names no index.
+const TextIndexName = "text_search"
+
 // CompoundIndex represents a compound index configuration.
-// It defines the fields that make up the index and whether it should be unique.
+// It defines the fields that make up the index, their key values and the index options.
 type CompoundIndex struct {
 	Fields []string
 	Unique bool
+
+	// Values holds the key value of each field: 1, -1, "hashed", "text" or "2dsphere".
+	// A missing value means ascending.
+	Values []any
+
+	// Positions holds the explicit key position of each field, 0 when unset.
+	Positions []int
+
+	// Sparse skips documents that lack the indexed fields.
+	Sparse bool
+
+	// Partial is an extended JSON filter restricting the indexed documents.
+	Partial string
+
+	// TTL expires documents this long after the indexed date. Nil means no expiry.
+	TTL *time.Duration
+
+	// Collation is the collation locale of the index.
+	Collation string
+
+	// Weights holds the weight of text fields that set one.
+	Weights map[string]int32
+}
+
+// Keys returns the ordered index keys.
+// Fields with a position come first, by position; the others follow in struct order.
+func (c *CompoundIndex) Keys() bson.D {
+	entries := c.entries()
+	keys := make(bson.D, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, bson.E{Key: e.field, Value: e.value})
+	}
+	return keys
+}
+
+type indexEntry struct {
+	field    string
+	value    any
+	position int
+}
+
+// entries returns the fields of the index in key order.
+func (c *CompoundIndex) entries() []indexEntry {
+	entries := make([]indexEntry, 0, len(c.Fields))
+	for i, field := range c.Fields {
+		e := indexEntry{field: field, value: 1}
+		if i < len(c.Values) && c.Values[i] != nil {
+			e.value = c.Values[i]
+		}
+		if i < len(c.Positions) {
+			e.position = c.Positions[i]
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].position, entries[j].position
+		if a == 0 || b == 0 {
+			return a != 0 && b == 0
+		}
+		return a < b
+	})
+	return entries
+}
+
+// add appends a field to the index in the given direction and applies the options of info.
+func (c *CompoundIndex) add(field string, position int, descending bool, info TagInfo) {
+	var value any = 1
+	switch {
+	case info.Hashed:
+		value = "hashed"
+	case info.Text:
+		value = "text"
+	case info.Sphere:
+		value = "2dsphere"
+	case descending:
+		value = -1
+	}
+	c.Fields = append(c.Fields, field)
+	c.Values = append(c.Values, value)
+	c.Positions = append(c.Positions, position)
+
+	if info.Text && info.TextWeight > 0 {
+		if c.Weights == nil {
+			c.Weights = make(map[string]int32)
+		}
+		c.Weights[field] = info.TextWeight
+	}
+	c.merge(&CompoundIndex{
+		Sparse:    info.Sparse,
+		Partial:   info.Partial,
+		TTL:       info.TTL,
+		Collation: info.Collation,
+	})
+}
+
+// merge combines the options of other into the index.
+func (c *CompoundIndex) merge(other *CompoundIndex) {
+	c.Unique = c.Unique || other.Unique
+	c.Sparse = c.Sparse || other.Sparse
+	if other.Partial != "" {
+		c.Partial = other.Partial
+	}
+	if other.TTL != nil {
+		c.TTL = other.TTL
+	}
+	if other.Collation != "" {
+		c.Collation = other.Collation
+	}
+	for k, v := range other.Weights {
+		if c.Weights == nil {
+			c.Weights = make(map[string]int32)
+		}
+		c.Weights[k] = v
+	}
 }
 
 // ParseModelIndexes parses struct tags to extract index configuration.
@@ -231,8 +350,12 @@ func ParseModelIndexes(model any) (modelName string, indexInfo map[string]*Compo
 							Unique: false,
 						}
 					}
-					indexInfo[k].Fields = append(indexInfo[k].Fields, v.Fields...)
-					indexInfo[k].Unique = indexInfo[k].Unique || v.Unique
+					for _, e := range v.entries() {
+						indexInfo[k].Fields = append(indexInfo[k].Fields, e.field)
+						indexInfo[k].Values = append(indexInfo[k].Values, e.value)
+						indexInfo[k].Positions = append(indexInfo[k].Positions, e.position)
+					}
+					indexInfo[k].merge(v)
 				}
 			}
 			continue
@@ -249,11 +372,20 @@ func ParseModelIndexes(model any) (modelName string, indexInfo map[string]*Compo
 					Unique: true,
 				}
 			}
-			indexInfo[dbTags.UniqueName].Fields = append(indexInfo[dbTags.UniqueName].Fields, indexName)
+			// the options belong to the unique index only when the tag declares no other index
+			var opts TagInfo
+			if !dbTags.Index {
+				opts = TagInfo{Sparse: dbTags.Sparse, Partial: dbTags.Partial, TTL: dbTags.TTL, Collation: dbTags.Collation}
+			}
+			indexInfo[dbTags.UniqueName].add(indexName, dbTags.UniquePosition, dbTags.UniqueDescending, opts)
 		}
 		if dbTags.Index {
 			if dbTags.IndexName == "" {
 				dbTags.IndexName = indexName
+				if dbTags.Text {
+					// a collection has at most one text index, so unnamed text fields share it
+					dbTags.IndexName = TextIndexName
+				}
 			}
 			if indexInfo[dbTags.IndexName] == nil {
 				indexInfo[dbTags.IndexName] = &CompoundIndex{
@@ -261,7 +393,7 @@ func ParseModelIndexes(model any) (modelName string, indexInfo map[string]*Compo
 					Unique: false,
 				}
 			}
-			indexInfo[dbTags.IndexName].Fields = append(indexInfo[dbTags.IndexName].Fields, indexName)
+			indexInfo[dbTags.IndexName].add(indexName, dbTags.IndexPosition, dbTags.IndexDescending, dbTags)
 		}
 	}
 
@@ -284,22 +416,84 @@ type TagInfo struct {
 
 	// PrimaryKey indicates if the field is the primary key.
 	PrimaryKey bool
+
+	// UniqueDescending orders the field descending in its unique index.
+	UniqueDescending bool
+
+	// IndexDescending orders the field descending in its index.
+	IndexDescending bool
+
+	// Sparse skips documents that lack the field.
+	Sparse bool
+
+	// Partial is an extended JSON filter restricting the indexed documents.
+	Partial string
+
+	// TTL expires documents this long after the field's date. Nil means no expiry.
+	TTL *time.Duration
+
+	// Collation is the collation locale of the index.
+	Collation string
+
+	// Hashed creates a hashed index on the field.
+	Hashed bool
+
+	// Text creates a text index on the field.
+	Text bool
+
+	// TextWeight is the weight of the field in its text index, 0 for the default.
+	TextWeight int32
+
+	// Sphere creates a 2dsphere index on the field.
+	Sphere bool
+
+	// UniquePosition is the 1-based key position of the field in its unique index, 0 when unset.
+	UniquePosition int
+
+	// IndexPosition is the 1-based key position of the field in its index, 0 when unset.
+	IndexPosition int
+
+	// CreatedAt marks the field set once when the document is created.
+	CreatedAt bool
+
+	// UpdatedAt marks the field set on every write.
+	UpdatedAt bool
+
+	// Version marks the integer field used for optimistic concurrency.
+	Version bool
+
+	// DeletedAt marks the time field that makes deletes soft.
+	DeletedAt bool
 }
 
 // ParseTag parses a database tag string and returns TagInfo.
 // Format: index=name,unique=name,pk
 //
+// Index and unique names accept a ":<position>" suffix fixing the field's place in a compound index
+// and a ":-" suffix for descending order ("index=-" for an unnamed index); the direction only applies
+// to the index it follows.
+// The options sparse, partial=<extJSON>, ttl=<duration|seconds>, collation=<locale>, hashed,
+// text[=weight] and 2dsphere apply to the field's index; hashed, text and 2dsphere imply index.
+// A tag declaring only unique applies sparse, partial, ttl and collation to the unique index instead.
+// Text fields without an index name share the TextIndexName index.
+//
 // Example:
 //
 //	info := ParseTag("index=user_name,unique=user_email")
 //	// info.Index = true, info.IndexName = "user_name"
 //	// info.Unique = true, info.UniqueName = "user_email"
+//
+//	info = ParseTag("index=name_region:2:-")
+//	// info.IndexName = "name_region", info.IndexPosition = 2, info.IndexDescending = true
+//
+//	info = ParseTag(`index=-,sparse,partial={"age":{"$gt":18}}`)
+//	// info.Index = true, info.IndexDescending = true, info.Sparse = true
 func ParseTag(tag string) TagInfo {
 	info := TagInfo{}
 
-	multTypes := strings.Split(strings.Trim(tag, ", ;"), ",")
+	multTypes := splitTag(strings.Trim(tag, ", ;"))
 	for _, v := range multTypes {
-		arr := strings.Split(v, "=")
+		arr := strings.SplitN(v, "=", 2)
 		if len(arr) > 0 {
 			k := strings.ToLower(strings.TrimSpace(arr[0]))
 			if k == "" {
@@ -314,12 +508,45 @@ func ParseTag(tag string) TagInfo {
 			switch k {
 			case "unique":
 				info.Unique = true
-				info.UniqueName = val
+				info.UniqueName, info.UniquePosition, info.UniqueDescending = parseIndexName(val)
 			case "index":
 				info.Index = true
-				info.IndexName = val
+				info.IndexName, info.IndexPosition, info.IndexDescending = parseIndexName(val)
 			case "pk":
 				info.PrimaryKey = true
+			case "created_at":
+				info.CreatedAt = true
+			case "updated_at":
+				info.UpdatedAt = true
+			case "version":
+				info.Version = true
+			case "deleted_at":
+				info.DeletedAt = true
+			case "sparse":
+				info.Sparse = true
+			case "partial":
+				info.Partial = val
+			case "ttl":
+				if d, err := time.ParseDuration(val); err == nil {
+					info.TTL = &d
+				} else if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+					d := time.Duration(n) * time.Second
+					info.TTL = &d
+				}
+			case "collation":
+				info.Collation = val
+			case "hashed":
+				info.Index = true
+				info.Hashed = true
+			case "text":
+				info.Index = true
+				info.Text = true
+				if n, err := strconv.ParseInt(val, 10, 32); err == nil {
+					info.TextWeight = int32(n)
+				}
+			case "2dsphere":
+				info.Index = true
+				info.Sphere = true
 			}
 		}
 	}
@@ -327,6 +554,44 @@ func ParseTag(tag string) TagInfo {
 	return info
 }
 
+// parseIndexName returns the group name, position and direction of an index or unique value.
+func parseIndexName(val string) (name string, position int, descending bool) {
+	for i, segment := range strings.Split(val, ":") {
+		switch segment = strings.TrimSpace(segment); segment {
+		case "-", "desc":
+			descending = true
+		case "", "+", "asc":
+		default:
+			if n, err := strconv.Atoi(segment); err == nil && i > 0 && n > 0 {
+				position = n
+			} else {
+				name = segment
+			}
+		}
+	}
+	return name, position, descending
+}
+
+// splitTag splits a tag on commas outside of braces and brackets, so partial filters may contain commas.
+func splitTag(tag string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range tag {
+		switch c {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, tag[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tag[start:])
+}
+
 // NewModelType creates a new instance of the given model type.
 // Returns a pointer to a new instance of the same type.
 func NewModelType(model any) any {
@@ -354,15 +619,43 @@ func NewModelType(model any) any {
 //	doc := mongo.Map().Set("name", "John").Set("age", 30)
 //	user := mongo.ToEntity[User](doc)
 func ToEntity[T any](m M) *T {
+	o, err := Decode[T](m)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+// Decode converts a MongoDB document to a typed struct.
+// Unlike ToEntity, it returns decoding errors instead of panicking.
+//
+// Example:
+//
+//	user, err := mongo.Decode[User](doc)
+func Decode[T any](m M) (*T, error) {
 	o := new(T)
 	raw, err := bson.Marshal(m)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	if err := bson.Unmarshal(raw, o); err != nil {
-		panic(err)
+		return nil, err
 	}
-	return o
+	return o, nil
+}
+
+// DecodeAll converts a slice of MongoDB documents to a slice of typed structs.
+// It stops at the first document that fails to decode.
+func DecodeAll[T any](items []M) ([]*T, error) {
+	os := make([]*T, 0, len(items))
+	for _, v := range items {
+		o, err := Decode[T](v)
+		if err != nil {
+			return nil, err
+		}
+		os = append(os, o)
+	}
+	return os, nil
 }
 
 // ToEntities converts a slice of MongoDB documents to a slice of typed structs.
@@ -7,6 +7,7 @@ import (
 
 	"github.com/liran/mongo"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func TestGetModelName(t *testing.T) {
@@ -364,6 +365,111 @@ func TestParseTag(t *testing.T) {
 				PrimaryKey: false,
 			},
 		},
+		// Index options
+		{
+			name: "descending index",
+			tag:  "index=-",
+			expected: mongo.TagInfo{
+				Index:           true,
+				IndexDescending: true,
+			},
+		},
+		{
+			name: "descending named unique",
+			tag:  "unique=user_email:-",
+			expected: mongo.TagInfo{
+				Unique:           true,
+				UniqueName:       "user_email",
+				UniqueDescending: true,
+			},
+		},
+		{
+			name: "sparse partial with commas",
+			tag:  `unique,sparse,partial={"age":{"$gt":18},"status":"a"}`,
+			expected: mongo.TagInfo{
+				Unique:  true,
+				Sparse:  true,
+				Partial: `{"age":{"$gt":18},"status":"a"}`,
+			},
+		},
+		{
+			name: "ttl duration and collation",
+			tag:  "index,ttl=24h,collation=fr",
+			expected: mongo.TagInfo{
+				Index:     true,
+				TTL:       mongo.Pointer(24 * time.Hour),
+				Collation: "fr",
+			},
+		},
+		{
+			name: "ttl seconds",
+			tag:  "index,ttl=3600",
+			expected: mongo.TagInfo{
+				Index: true,
+				TTL:   mongo.Pointer(time.Hour),
+			},
+		},
+		{
+			name: "special index types",
+			tag:  "text=5,index=search",
+			expected: mongo.TagInfo{
+				Index:      true,
+				IndexName:  "search",
+				Text:       true,
+				TextWeight: 5,
+			},
+		},
+		{
+			name: "hashed and 2dsphere",
+			tag:  "hashed,2dsphere",
+			expected: mongo.TagInfo{
+				Index:  true,
+				Hashed: true,
+				Sphere: true,
+			},
+		},
+		{
+			name: "index position",
+			tag:  "index=name_region:2",
+			expected: mongo.TagInfo{
+				Index:         true,
+				IndexName:     "name_region",
+				IndexPosition: 2,
+			},
+		},
+		{
+			name: "unique position and direction",
+			tag:  "unique=email_domain:1:-",
+			expected: mongo.TagInfo{
+				Unique:           true,
+				UniqueName:       "email_domain",
+				UniquePosition:   1,
+				UniqueDescending: true,
+			},
+		},
+		{
+			name: "timestamp markers",
+			tag:  "created_at,updated_at",
+			expected: mongo.TagInfo{
+				CreatedAt: true,
+				UpdatedAt: true,
+			},
+		},
+		{
+			name: "version marker",
+			tag:  "version",
+			expected: mongo.TagInfo{
+				Version: true,
+			},
+		},
+		{
+			name: "soft delete marker",
+			tag:  "deleted_at,index",
+			expected: mongo.TagInfo{
+				Index:     true,
+				DeletedAt: true,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -373,3 +479,95 @@ func TestParseTag(t *testing.T) {
 		})
 	}
 }
+
+func TestModelIndexSpecsOrder(t *testing.T) {
+	type Address struct {
+		Region string `bson:"region" db:"index=name_region:1"`
+		City   string `bson:"city" db:"index=name_region"`
+	}
+
+	type User struct {
+		ID   string `bson:"_id"`
+		Name string `bson:"name" db:"index=name_region:2:-"`
+		Address
+	}
+
+	for i := 0; i < 10; i++ {
+		name, specs, err := mongo.ModelIndexSpecs(&User{})
+		require.NoError(t, err)
+		require.Equal(t, "user", name)
+		require.Len(t, specs, 1)
+		require.Equal(t, "name_region", specs[0].Name)
+		require.Equal(t, bson.D{
+			{Key: "region", Value: 1},
+			{Key: "name", Value: -1},
+			{Key: "city", Value: 1},
+		}, specs[0].Keys)
+	}
+
+	type Conflict struct {
+		A string `bson:"a" db:"index=ab:1"`
+		B string `bson:"b" db:"index=ab:1"`
+	}
+	_, _, err := mongo.ModelIndexSpecs(&Conflict{})
+	require.Error(t, err)
+}
+
+func TestModelIndexSpecsPerIndexOptions(t *testing.T) {
+	type Doc struct {
+		ID    string `bson:"_id"`
+		A     string `bson:"a" db:"index=a:-,unique=b"`
+		X     string `bson:"x" db:"unique,index=x_hashed,hashed"`
+		Title string `bson:"title" db:"text"`
+		Body  string `bson:"body" db:"text=5"`
+		Email string `bson:"email" db:"unique,sparse"`
+	}
+
+	_, specs, err := mongo.ModelIndexSpecs(&Doc{})
+	require.NoError(t, err)
+	byName := make(map[string]mongo.IndexSpec)
+	for _, spec := range specs {
+		byName[spec.Name] = spec
+	}
+	require.Len(t, byName, 6)
+
+	// the direction only applies to the index it follows
+	require.Equal(t, bson.D{{Key: "a", Value: -1}}, byName["a_-1"].Keys)
+	require.False(t, byName["a_-1"].Unique)
+	require.Equal(t, bson.D{{Key: "a", Value: 1}}, byName["a_1"].Keys)
+	require.True(t, byName["a_1"].Unique)
+
+	// the hashed type belongs to the index, not to the unique key
+	require.Equal(t, bson.D{{Key: "x", Value: "hashed"}}, byName["x_hashed"].Keys)
+	require.False(t, byName["x_hashed"].Unique)
+	require.Equal(t, bson.D{{Key: "x", Value: 1}}, byName["x_1"].Keys)
+	require.True(t, byName["x_1"].Unique)
+
+	// unnamed text fields share the collection's one text index
+	text := byName[mongo.TextIndexName]
+	require.Equal(t, bson.D{{Key: "title", Value: "text"}, {Key: "body", Value: "text"}}, text.Keys)
+	require.Equal(t, map[string]int32{"body": 5}, text.Weights)
+
+	// a unique-only tag keeps its options
+	require.True(t, byName["email_1"].Unique)
+	require.True(t, byName["email_1"].Sparse)
+}
+
+func TestDecode(t *testing.T) {
+	type User struct {
+		ID   string `bson:"_id"`
+		Name string `bson:"name"`
+		Age  int64  `bson:"age"`
+	}
+
+	user, err := mongo.Decode[User](mongo.Map().Set("_id", "1").Set("name", "John").Set("age", int64(30)))
+	require.NoError(t, err)
+	require.Equal(t, &User{ID: "1", Name: "John", Age: 30}, user)
+
+	_, err = mongo.Decode[User](mongo.Map().Set("age", "thirty"))
+	require.Error(t, err)
+
+	users, err := mongo.DecodeAll[User]([]mongo.M{mongo.Map().Set("_id", "1"), mongo.Map().Set("_id", "2")})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+}
@@ -0,0 +1,208 @@
+// Package mongo provides change stream watching with resumable tokens.
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeEvent is a change stream event. FullDocument is set for inserts and replaces,
+// and for updates when WatchOptions.FullDocument is enabled.
+type ChangeEvent struct {
+	// ResumeToken identifies the event in the change stream.
+	ResumeToken bson.Raw `bson:"_id"`
+
+	// OperationType is insert, update, replace, delete, drop, rename, dropDatabase or invalidate.
+	OperationType string `bson:"operationType"`
+
+	Namespace struct {
+		DB         string `bson:"db"`
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+
+	DocumentKey       M                   `bson:"documentKey"`
+	FullDocument      M                   `bson:"fullDocument,omitempty"`
+	UpdateDescription *UpdateDescription  `bson:"updateDescription,omitempty"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+}
+
+// UpdateDescription describes the fields changed by an update event.
+type UpdateDescription struct {
+	UpdatedFields M        `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+}
+
+// Decode unmarshals the full document of the event into v.
+// Returns ErrRecordNotFound if the event carries no full document, e.g. for deletes.
+func (e *ChangeEvent) Decode(v any) error {
+	if e.FullDocument == nil {
+		return ErrRecordNotFound
+	}
+	raw, err := bson.Marshal(e.FullDocument)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, v)
+}
+
+// TokenStore persists change stream resume tokens by key.
+type TokenStore interface {
+	// LoadToken returns the saved token for key, or nil if there is none.
+	LoadToken(ctx context.Context, key string) (bson.Raw, error)
+
+	// SaveToken saves the token for key.
+	SaveToken(ctx context.Context, key string, token bson.Raw) error
+}
+
+// resumeTokenSuffix names the default token collection after the watched model or database.
+const resumeTokenSuffix = "_resume_token"
+
+// WatchOptions configures Model.Watch and Database.Watch.
+type WatchOptions struct {
+	// FullDocument requests the current document for update events (fullDocument: updateLookup).
+	FullDocument bool
+
+	// TokenStore persists the resume token after every handled event and resumes from it.
+	// Defaults to a collection named after the model (or database) with the "_resume_token" suffix.
+	TokenStore TokenStore
+
+	// Key identifies the stream in the token store. Defaults to the collection (or database) name.
+	Key string
+}
+
+type collectionTokenStore struct {
+	coll Store
+}
+
+// NewTokenStore creates a TokenStore that keeps tokens in the given collection, one document per key.
+func NewTokenStore(db *Database, collection string) TokenStore {
+	return &collectionTokenStore{coll: db.backend().Collection(collection)}
+}
+
+func (s *collectionTokenStore) LoadToken(ctx context.Context, key string) (bson.Raw, error) {
+	var doc struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := s.coll.FindOne(ctx, GetIDFilter(key)).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return doc.Token, nil
+}
+
+func (s *collectionTokenStore) SaveToken(ctx context.Context, key string, token bson.Raw) error {
+	doc := bson.D{
+		{Key: "_id", Value: key},
+		{Key: "token", Value: token},
+		{Key: "updated_at", Value: time.Now()},
+	}
+	_, err := s.coll.ReplaceOne(ctx, GetIDFilter(key), doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Watch watches the model collection and calls cb for every change event matching filter.
+// The filter is applied to the events, e.g. mongo.Where("operationType").In("insert", "update").
+// Watching stops when cb returns false or an error, or when ctx is done. The resume token
+// is saved after every handled event, so a later Watch continues where this one stopped.
+//
+// Example:
+//
+//	err := txn.Model(&User{}).Watch(ctx, nil, func(e mongo.ChangeEvent) (bool, error) {
+//	    user := &User{}
+//	    if err := e.Decode(user); err != nil {
+//	        return false, err
+//	    }
+//	    cache.Invalidate(user.ID)
+//	    return true, nil
+//	}, func(o *mongo.WatchOptions) {
+//	    o.FullDocument = true
+//	})
+func (m *Model) Watch(ctx context.Context, filter any, cb func(e ChangeEvent) (bool, error), opts ...func(o *WatchOptions)) error {
+	name := m.coll.Name()
+	opt := &WatchOptions{Key: name}
+	for _, v := range opts {
+		v(opt)
+	}
+	if opt.TokenStore == nil {
+		opt.TokenStore = NewTokenStore(m.txn.db, name+resumeTokenSuffix)
+	}
+
+	op := &Operation{Name: "Watch", Filter: filter, Ctx: ctx}
+	_, err := run(m, op, func(m *Model, op *Operation) (any, error) {
+		return nil, watch(op.Ctx, m.coll.Watch, op.Filter, cb, opt)
+	})
+	return err
+}
+
+// Watch watches all collections of the database, see Model.Watch.
+func (d *Database) Watch(ctx context.Context, filter any, cb func(e ChangeEvent) (bool, error), opts ...func(o *WatchOptions)) error {
+	name := ""
+	if d.Database != nil {
+		name = d.Name()
+	}
+	opt := &WatchOptions{Key: name}
+	for _, v := range opts {
+		v(opt)
+	}
+	if opt.TokenStore == nil {
+		opt.TokenStore = NewTokenStore(d, name+resumeTokenSuffix)
+	}
+	return watch(ctx, d.backend().Watch, filter, cb, opt)
+}
+
+type watchFunc func(ctx context.Context, pipeline any, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+
+func watch(ctx context.Context, fn watchFunc, filter any, cb func(e ChangeEvent) (bool, error), opt *WatchOptions) error {
+	pipeline := NewPipeline()
+	if filter != nil {
+		pipeline = pipeline.Match(filter)
+	}
+
+	csOpt := options.ChangeStream()
+	if opt.FullDocument {
+		csOpt.SetFullDocument(options.UpdateLookup)
+	}
+	token, err := opt.TokenStore.LoadToken(ctx, opt.Key)
+	if err != nil {
+		return err
+	}
+	if token != nil {
+		csOpt.SetStartAfter(token)
+	}
+
+	stream, err := fn(ctx, pipeline, csOpt)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var e ChangeEvent
+		if err := stream.Decode(&e); err != nil {
+			return err
+		}
+		ok, err := cb(e)
+		if err != nil {
+			return err
+		}
+		if err := opt.TokenStore.SaveToken(ctx, opt.Key, stream.ResumeToken()); err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return stream.Err()
+}